@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ListFunctionalAbilities returns every functional ability defined for the application.
+func (c *Client) ListFunctionalAbilities() ([]FunctionalAbilities, error) {
+	var abilities []FunctionalAbilities
+	err := c.keystone("GET", "adminservice/keystone/v1/application/"+c.c3poApplicationID+"/functionalability", "", nil, &abilities)
+	if err != nil {
+		return nil, err
+	}
+	return abilities, nil
+}
+
+// FindFunctionalAbilities returns the functional abilities whose name matches
+// name (every ability when name is empty), best match first.
+func (c *Client) FindFunctionalAbilities(name string, mode MatchMode, limit int) ([]FunctionalAbilities, error) {
+	abilities, err := c.ListFunctionalAbilities()
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		sort.Slice(abilities, func(i, j int) bool {
+			return strings.ToLower(abilities[i].Name) < strings.ToLower(abilities[j].Name)
+		})
+		if limit > 0 && len(abilities) > limit {
+			abilities = abilities[:limit]
+		}
+		return abilities, nil
+	}
+
+	names := make([]string, len(abilities))
+	for i, ability := range abilities {
+		names[i] = ability.Name
+	}
+
+	matches, err := c.matchNames(names, name, mode, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]FunctionalAbilities, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, abilities[m.Index])
+	}
+	return result, nil
+}
+
+// RoleAbilityIds returns the ids of the functional abilities assigned to role.
+// Keystone returns RoleFunctionalAbilities either as a list of ids or as a list
+// of objects carrying a FunctionalAbilityId (or, failing that, an Id).
+func RoleAbilityIds(role Role) []string {
+	items, ok := role.RoleFunctionalAbilities.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var ids []string
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			ids = append(ids, v)
+		case map[string]interface{}:
+			if id, ok := v["FunctionalAbilityId"].(string); ok && id != "" {
+				ids = append(ids, id)
+			} else if id, ok := v["Id"].(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// EntityAccess flattens FunctionalAbilityEntityAccess into one readable line
+// per entity, e.g. "EntityName=Finance, AccessLevel=Read".
+func EntityAccess(ability FunctionalAbilities) []string {
+	var lines []string
+
+	switch v := ability.FunctionalAbilityEntityAccess.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range v {
+			lines = append(lines, formatEntity(item))
+		}
+	default:
+		lines = append(lines, formatEntity(v))
+	}
+	return lines
+}
+
+func formatEntity(item interface{}) string {
+	entity, ok := item.(map[string]interface{})
+	if !ok {
+		return fmt.Sprint(item)
+	}
+
+	keys := make([]string, 0, len(entity))
+	for key := range entity {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := entity[key]
+		if value == nil {
+			continue
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			raw, _ := json.Marshal(value)
+			parts = append(parts, fmt.Sprintf("%s=%s", key, raw))
+		default:
+			parts = append(parts, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
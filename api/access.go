@@ -0,0 +1,306 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RoleGrant is a user holding a role through membership of a group bound to it.
+type RoleGrant struct {
+	User  User  `json:"User"`
+	Group Group `json:"Group"`
+	Role  Role  `json:"Role"`
+}
+
+// AccessPath is one way a user holds a functional ability: through membership
+// of a group, bound to a role, which carries the ability.
+type AccessPath struct {
+	User    User                `json:"User"`
+	Group   Group               `json:"Group"`
+	Role    Role                `json:"Role"`
+	Ability FunctionalAbilities `json:"Ability"`
+}
+
+// String renders the grant as "HUBID -> group -> role".
+func (g RoleGrant) String() string {
+	return strings.Join([]string{g.User.IdAtSourceSystem, g.Group.Name, g.Role.Name}, " -> ")
+}
+
+// String renders the path as "HUBID -> group -> role -> ability".
+func (p AccessPath) String() string {
+	return strings.Join([]string{p.User.IdAtSourceSystem, p.Group.Name, p.Role.Name, p.Ability.Name}, " -> ")
+}
+
+// RoleGrants returns every user -> group -> role grant in the state, sorted by
+// user, group and role.
+func (s *State) RoleGrants() []RoleGrant {
+	var grants []RoleGrant
+	for _, group := range s.Groups {
+		for _, binding := range group.Roles {
+			role, ok := s.Role(binding.RoleId)
+			if !ok {
+				continue
+			}
+			for _, user := range group.Members {
+				grants = append(grants, RoleGrant{User: user, Group: group.Group, Role: role})
+			}
+		}
+	}
+
+	sort.SliceStable(grants, func(i, j int) bool {
+		a, b := grants[i], grants[j]
+		if ka, kb := strings.ToLower(a.User.IdAtSourceSystem), strings.ToLower(b.User.IdAtSourceSystem); ka != kb {
+			return ka < kb
+		}
+		if ka, kb := strings.ToLower(a.Group.Name), strings.ToLower(b.Group.Name); ka != kb {
+			return ka < kb
+		}
+		return strings.ToLower(a.Role.Name) < strings.ToLower(b.Role.Name)
+	})
+	return grants
+}
+
+// AccessPaths returns every user -> group -> role -> ability path in the
+// state, sorted by user, group, role and ability. Roles without abilities
+// yield no path.
+func (s *State) AccessPaths() []AccessPath {
+	var paths []AccessPath
+	for _, grant := range s.RoleGrants() {
+		for _, ability := range s.RoleAbilities(grant.Role) {
+			paths = append(paths, AccessPath{User: grant.User, Group: grant.Group, Role: grant.Role, Ability: ability})
+		}
+	}
+	return paths
+}
+
+// UserAccessPaths returns the access paths of one user, by HubID.
+func (s *State) UserAccessPaths(hubid string) []AccessPath {
+	var paths []AccessPath
+	for _, path := range s.AccessPaths() {
+		if strings.EqualFold(path.User.IdAtSourceSystem, hubid) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Explanation is one path by which a user holds a role or functional ability,
+// with what makes the path dynamic or conditional.
+type Explanation struct {
+	Path         string
+	HubID        string
+	Group        string
+	Role         string
+	Ability      string   `json:",omitempty"`
+	EntityAccess []string `json:",omitempty"`
+	// DynamicGroup and DynamicRole hold the DynamicAssignmentId, when the
+	// membership or role is assigned by a Keystone rule rather than by hand.
+	DynamicGroup string `json:",omitempty"`
+	DynamicRole  string `json:",omitempty"`
+	// Condition is the role's ConditionalExpression and ConditionResult what
+	// it gives for the user's attributes: "matches", "does not match" or the
+	// reason it could not be evaluated.
+	Condition       string `json:",omitempty"`
+	ConditionResult string `json:",omitempty"`
+}
+
+// ExplainAccess returns every path giving the user (by HubID) the named role,
+// or the named functional ability when ability is true. Names are matched
+// exactly, ignoring case; an unknown name is an error.
+func (s *State) ExplainAccess(hubid string, name string, ability bool) ([]Explanation, error) {
+	var explanations []Explanation
+
+	if ability {
+		if _, ok := s.FunctionalAbilityByName(name); !ok {
+			return nil, fmt.Errorf("no functional ability named %q", name)
+		}
+		for _, path := range s.UserAccessPaths(hubid) {
+			if !strings.EqualFold(path.Ability.Name, name) {
+				continue
+			}
+			explanation := newExplanation(RoleGrant{User: path.User, Group: path.Group, Role: path.Role})
+			explanation.Path = path.String()
+			explanation.Ability = path.Ability.Name
+			explanation.EntityAccess = EntityAccess(path.Ability)
+			explanations = append(explanations, explanation)
+		}
+		return explanations, nil
+	}
+
+	if _, ok := s.RoleByName(name); !ok {
+		return nil, fmt.Errorf("no role named %q", name)
+	}
+	for _, grant := range s.RoleGrants() {
+		if strings.EqualFold(grant.User.IdAtSourceSystem, hubid) && strings.EqualFold(grant.Role.Name, name) {
+			explanations = append(explanations, newExplanation(grant))
+		}
+	}
+	return explanations, nil
+}
+
+func newExplanation(grant RoleGrant) Explanation {
+	explanation := Explanation{
+		Path:         grant.String(),
+		HubID:        grant.User.IdAtSourceSystem,
+		Group:        grant.Group.Name,
+		Role:         grant.Role.Name,
+		DynamicGroup: dynamicAssignment(grant.Group.DynamicAssignmentId),
+		DynamicRole:  dynamicAssignment(grant.Role.DynamicAssignmentId),
+	}
+
+	expression, err := ParseExpression(grant.Role.ConditionalExpression)
+	switch {
+	case err != nil:
+		explanation.Condition = ExpressionString(grant.Role.ConditionalExpression)
+		explanation.ConditionResult = err.Error()
+	case expression != nil:
+		explanation.Condition = expression.String()
+		explanation.ConditionResult = "does not match"
+		if matched, _ := EvaluateExpression(expression, UserAttributes(grant.User)); matched {
+			explanation.ConditionResult = "matches"
+		}
+	}
+	return explanation
+}
+
+// dynamicAssignment returns a DynamicAssignmentId as text, "" when unset.
+func dynamicAssignment(id interface{}) string {
+	switch v := id.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	}
+	return fmt.Sprint(id)
+}
+
+// AccessQuery selects access by role name, functional ability name or Nimbus
+// folder (a value in an ability's entity access). Names are matched with Mode.
+type AccessQuery struct {
+	Role         string
+	Ability      string
+	NimbusFolder string
+	Mode         MatchMode
+}
+
+// AccessHolder is a user who effectively holds the queried access, with the
+// groups and roles granting it.
+type AccessHolder struct {
+	User   User     `json:"User"`
+	Groups []string `json:"Groups"`
+	Roles  []string `json:"Roles"`
+}
+
+// WhoHas returns every user holding a role matching the query, or a role
+// carrying a matching functional ability, once per user. It fails when the
+// query matches no role or ability at all.
+func (c *Client) WhoHas(state *State, query AccessQuery) ([]AccessHolder, error) {
+	roleIds := make(map[string]bool)
+
+	if query.Role != "" {
+		names := make([]string, len(state.Roles))
+		for i, role := range state.Roles {
+			names[i] = role.Name
+		}
+		matches, err := c.matchNames(names, query.Role, query.Mode, 0)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no role matches %q", query.Role)
+		}
+		for _, m := range matches {
+			roleIds[state.Roles[m.Index].Id] = true
+		}
+	}
+
+	if query.Ability != "" || query.NimbusFolder != "" {
+		abilityIds := make(map[string]bool)
+		if query.Ability != "" {
+			names := make([]string, len(state.FunctionalAbilities))
+			for i, ability := range state.FunctionalAbilities {
+				names[i] = ability.Name
+			}
+			matches, err := c.matchNames(names, query.Ability, query.Mode, 0)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no functional ability matches %q", query.Ability)
+			}
+			for _, m := range matches {
+				abilityIds[state.FunctionalAbilities[m.Index].Id] = true
+			}
+		}
+		if query.NimbusFolder != "" {
+			found := false
+			for _, ability := range state.FunctionalAbilities {
+				if entityAccessHas(ability.FunctionalAbilityEntityAccess, query.NimbusFolder) {
+					abilityIds[ability.Id] = true
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no functional ability gives access to Nimbus folder %q", query.NimbusFolder)
+			}
+		}
+
+		for _, role := range state.Roles {
+			for _, id := range RoleAbilityIds(role) {
+				if abilityIds[id] {
+					roleIds[role.Id] = true
+				}
+			}
+		}
+	}
+
+	holders := make(map[string]*AccessHolder)
+	var order []string
+	for _, grant := range state.RoleGrants() {
+		if !roleIds[grant.Role.Id] {
+			continue
+		}
+		key := strings.ToLower(grant.User.IdAtSourceSystem)
+		holder, ok := holders[key]
+		if !ok {
+			holder = &AccessHolder{User: grant.User}
+			holders[key] = holder
+			order = append(order, key)
+		}
+		if !containsFold(holder.Groups, grant.Group.Name) {
+			holder.Groups = append(holder.Groups, grant.Group.Name)
+		}
+		if !containsFold(holder.Roles, grant.Role.Name) {
+			holder.Roles = append(holder.Roles, grant.Role.Name)
+		}
+	}
+
+	result := make([]AccessHolder, 0, len(order))
+	for _, key := range order {
+		result = append(result, *holders[key])
+	}
+	return result, nil
+}
+
+// entityAccessHas reports whether any string in an entity access value is
+// name, ignoring case.
+func entityAccessHas(value interface{}, name string) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(name))
+	case []interface{}:
+		for _, item := range v {
+			if entityAccessHas(item, name) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if entityAccessHas(item, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute usage types. A group attribute applies to every member of the
+// group, a group-role attribute only to the role binding named by GroupRoleId.
+const (
+	UsageTypeGroup     = 0
+	UsageTypeGroupRole = 1
+)
+
+var usageTypeNames = map[int]string{
+	UsageTypeGroup:     "group",
+	UsageTypeGroupRole: "grouprole",
+}
+
+// UsageTypeName returns the CLI name of an attribute usage type.
+func UsageTypeName(usageType int) string {
+	if name, ok := usageTypeNames[usageType]; ok {
+		return name
+	}
+	return strconv.Itoa(usageType)
+}
+
+// ParseUsageType accepts a usage type by name ("group", "grouprole") or number.
+func ParseUsageType(usageType string) (int, error) {
+	value := strings.ToLower(strings.TrimSpace(usageType))
+	for n, name := range usageTypeNames {
+		if name == value {
+			return n, nil
+		}
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		if _, ok := usageTypeNames[n]; ok {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid usage type %q (expected group or grouprole)", usageType)
+}
+
+// ValidateGroupAttribute checks an attribute before it is sent to Keystone.
+func ValidateGroupAttribute(attr GroupAttributes) error {
+	if attr.GroupId == "" {
+		return fmt.Errorf("attribute %q has no GroupId", attr.AttributeName)
+	}
+	if strings.TrimSpace(attr.AttributeName) == "" {
+		return fmt.Errorf("attribute name cannot be empty")
+	}
+
+	switch attr.UsageType {
+	case UsageTypeGroup:
+		if attr.GroupRoleId != "" {
+			return fmt.Errorf("attribute %q: usage type group cannot target a group-role binding", attr.AttributeName)
+		}
+	case UsageTypeGroupRole:
+		if attr.GroupRoleId == "" {
+			return fmt.Errorf("attribute %q: usage type grouprole needs a group-role binding", attr.AttributeName)
+		}
+	default:
+		return fmt.Errorf("attribute %q: invalid usage type %d", attr.AttributeName, attr.UsageType)
+	}
+	return nil
+}
+
+// ListGroupAttributes returns every attribute set on the group, including the
+// ones attached to its group-role bindings.
+func (c *Client) ListGroupAttributes(groupId string) ([]GroupAttributes, error) {
+	var attrs []GroupAttributes
+	err := c.keystone("GET", "adminservice/keystone/v1/group/"+groupId+"/attribute", "", nil, &attrs)
+	if err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// AddGroupAttribute creates a new attribute on a group or group-role binding.
+func (c *Client) AddGroupAttribute(attr GroupAttributes) (GroupAttributes, error) {
+	if err := ValidateGroupAttribute(attr); err != nil {
+		return GroupAttributes{}, err
+	}
+
+	created := attr
+	err := c.keystone("POST", "adminservice/keystone/v1/group/"+attr.GroupId+"/attribute", "", attr, &created)
+	if err != nil {
+		return GroupAttributes{}, err
+	}
+	return created, nil
+}
+
+// UpdateGroupAttribute changes the value (or usage type) of an existing attribute.
+func (c *Client) UpdateGroupAttribute(attr GroupAttributes) error {
+	if attr.Id == "" {
+		return fmt.Errorf("attribute %q has no Id", attr.AttributeName)
+	}
+	if err := ValidateGroupAttribute(attr); err != nil {
+		return err
+	}
+	return c.keystone("PUT", "adminservice/keystone/v1/group/"+attr.GroupId+"/attribute/"+attr.Id, "", attr, nil)
+}
+
+// DeleteGroupAttribute removes an attribute from a group.
+func (c *Client) DeleteGroupAttribute(groupId string, attributeId string) error {
+	return c.keystone("DELETE", "adminservice/keystone/v1/group/"+groupId+"/attribute/"+attributeId, "", nil, nil)
+}
+
+// FindGroupAttribute returns the attribute named name on the group (when
+// groupRoleId is empty) or on the given group-role binding.
+func FindGroupAttribute(attrs []GroupAttributes, groupRoleId string, name string) (GroupAttributes, bool) {
+	for _, attr := range attrs {
+		if attr.GroupRoleId == groupRoleId && strings.EqualFold(attr.AttributeName, name) {
+			return attr, true
+		}
+	}
+	return GroupAttributes{}, false
+}
+
+// SetGroupAttribute adds the attribute, or changes its value if it is already
+// set on the same group or binding. It reports whether the attribute was created.
+func (c *Client) SetGroupAttribute(attr GroupAttributes) (GroupAttributes, bool, error) {
+	if err := ValidateGroupAttribute(attr); err != nil {
+		return GroupAttributes{}, false, err
+	}
+
+	attrs, err := c.ListGroupAttributes(attr.GroupId)
+	if err != nil {
+		return GroupAttributes{}, false, err
+	}
+
+	existing, ok := FindGroupAttribute(attrs, attr.GroupRoleId, attr.AttributeName)
+	if !ok {
+		created, err := c.AddGroupAttribute(attr)
+		return created, true, err
+	}
+
+	existing.AttributeValue = attr.AttributeValue
+	existing.UsageType = attr.UsageType
+	if err := c.UpdateGroupAttribute(existing); err != nil {
+		return GroupAttributes{}, false, err
+	}
+	return existing, false, nil
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// BackupSchemaVersion is the archive layout written by WriteBackup. ReadBackup
+// refuses archives with a newer schema.
+const BackupSchemaVersion = 1
+
+// Files inside a backup archive.
+const (
+	backupInfoFile     = "backup.json"
+	backupStateFile    = "state.json"
+	backupManifestFile = "manifest.yaml"
+)
+
+// BackupInfo describes a backup archive. It is stored as backup.json, the
+// first file of the archive, with the SHA-256 of every other file.
+type BackupInfo struct {
+	SchemaVersion int               `json:"SchemaVersion"`
+	ApplicationId string            `json:"ApplicationId"`
+	Environment   string            `json:"Environment"`
+	Created       time.Time         `json:"Created"`
+	CreatedBy     string            `json:"CreatedBy"`
+	Counts        map[string]int    `json:"Counts"`
+	Checksums     map[string]string `json:"Checksums"`
+}
+
+// WriteBackup saves the whole application state to a tar.gz archive at path.
+func (c *Client) WriteBackup(path string) (*BackupInfo, error) {
+	state, err := c.FetchState()
+	if err != nil {
+		return nil, err
+	}
+
+	stateData, err := json.MarshalIndent(state, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	manifestData, err := MarshalManifest(state.Manifest(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	members := 0
+	attributes := 0
+	bindings := 0
+	for _, group := range state.Groups {
+		members += len(group.Members)
+		attributes += len(group.Attributes)
+		bindings += len(group.Roles)
+	}
+
+	files := map[string][]byte{
+		backupStateFile:    stateData,
+		backupManifestFile: manifestData,
+	}
+
+	info := &BackupInfo{
+		SchemaVersion: BackupSchemaVersion,
+		ApplicationId: state.ApplicationId,
+		Environment:   c.c3poEnvironment,
+		Created:       time.Now().UTC(),
+		CreatedBy:     c.c3poUsername,
+		Counts: map[string]int{
+			"roles":               len(state.Roles),
+			"functionalAbilities": len(state.FunctionalAbilities),
+			"groups":              len(state.Groups),
+			"groupRoles":          bindings,
+			"attributes":          attributes,
+			"members":             members,
+		},
+		Checksums: make(map[string]string),
+	}
+	for name, data := range files {
+		info.Checksums[name] = checksum(data)
+	}
+
+	infoData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	tw := tar.NewWriter(zw)
+
+	names := []string{backupInfoFile, backupStateFile, backupManifestFile}
+	files[backupInfoFile] = infoData
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(files[name])), ModTime: info.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return info, f.Close()
+}
+
+// ReadBackup reads an archive written by WriteBackup, verifying its schema
+// version and checksums, and returns its description and state.
+func ReadBackup(path string) (*BackupInfo, *State, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading backup %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading backup %s: %w", path, err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading backup %s: %w", path, err)
+		}
+		files[header.Name] = data
+	}
+
+	infoData, ok := files[backupInfoFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a C3PO backup: no %s", path, backupInfoFile)
+	}
+
+	var info BackupInfo
+	if err := json.Unmarshal(infoData, &info); err != nil {
+		return nil, nil, fmt.Errorf("reading %s from %s: %w", backupInfoFile, path, err)
+	}
+	if info.SchemaVersion < 1 || info.SchemaVersion > BackupSchemaVersion {
+		return nil, nil, fmt.Errorf("backup %s has schema version %d, this c3po reads up to %d", path, info.SchemaVersion, BackupSchemaVersion)
+	}
+
+	if _, ok := info.Checksums[backupStateFile]; !ok {
+		return nil, nil, fmt.Errorf("backup %s has no checksum for %s", path, backupStateFile)
+	}
+	names := make([]string, 0, len(info.Checksums))
+	for name := range info.Checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, ok := files[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("backup %s is missing %s", path, name)
+		}
+		if sum := checksum(data); sum != info.Checksums[name] {
+			return nil, nil, fmt.Errorf("backup %s: checksum mismatch for %s", path, name)
+		}
+	}
+
+	var state State
+	if err := json.Unmarshal(files[backupStateFile], &state); err != nil {
+		return nil, nil, fmt.Errorf("reading %s from %s: %w", backupStateFile, path, err)
+	}
+	return &info, &state, nil
+}
+
+// PlanRestore plans the changes that bring back what the backup holds:
+// missing roles, groups, role bindings, attributes and members are recreated.
+// With revert, objects changed since the backup are also set back. Nothing
+// created since the backup is deleted. Names and expressions are restored as
+// Keystone held them, so neither the naming convention nor the expression
+// syntax is checked.
+func (c *Client) PlanRestore(backup *State, current *State, revert bool) (*Plan, error) {
+	plan, err := c.planManifest(backup.Manifest(), current, PruneNone, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ChangeCreate:
+			changes = append(changes, change)
+		case ChangeUpdate:
+			if revert {
+				changes = append(changes, change)
+			}
+		}
+	}
+	plan.Changes = changes
+	return plan, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClassificationLevelsFile optionally renames the DataClassification levels:
+//
+//	levels:
+//	  1: Public
+//	  4: Secret
+//
+// Levels it does not name keep their default name.
+const ClassificationLevelsFile = "~/.c3po/classification.yaml"
+
+// classificationLevels names the DataClassification values of functional
+// abilities.
+var classificationLevels = map[int]string{
+	0: "Unclassified",
+	1: "Public",
+	2: "Internal",
+	3: "Confidential",
+	4: "Restricted",
+}
+
+// LoadClassificationLevels reads level names from path, when it exists, over
+// the defaults. Two levels may not end up with the same name, ignoring case,
+// since ParseClassification looks levels up by name.
+func LoadClassificationLevels(path string) error {
+	absPath, err := AbsolutePath(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(absPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var file struct {
+		Levels map[int]string `yaml:"levels"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	levels := make(map[int]string, len(classificationLevels))
+	for level, name := range classificationLevels {
+		levels[level] = name
+	}
+	for level, name := range file.Levels {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s: level %d has no name", path, level)
+		}
+		levels[level] = strings.TrimSpace(name)
+	}
+
+	numbers := make([]int, 0, len(levels))
+	for level := range levels {
+		numbers = append(numbers, level)
+	}
+	sort.Ints(numbers)
+	named := make(map[string]int)
+	for _, level := range numbers {
+		key := strings.ToLower(levels[level])
+		if other, ok := named[key]; ok {
+			return fmt.Errorf("%s: levels %d and %d are both named %q", path, other, level, levels[level])
+		}
+		named[key] = level
+	}
+
+	classificationLevels = levels
+	return nil
+}
+
+// ClassificationName returns the name of a DataClassification level, or
+// "Level N" for a level without one.
+func ClassificationName(level int) string {
+	if name, ok := classificationLevels[level]; ok {
+		return name
+	}
+	return "Level " + strconv.Itoa(level)
+}
+
+// ParseClassification accepts a DataClassification level by name (ignoring
+// case) or number.
+func ParseClassification(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, nil
+	}
+	for level, name := range classificationLevels {
+		if strings.EqualFold(name, value) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown data classification %q", value)
+}
+
+// ClassificationExposure is who and what grants the functional abilities of
+// one DataClassification level: the roles carrying them, the groups bound to
+// those roles and their users. Highest marks the highest level anyone holds.
+// Changes, compared with an earlier state, is set by ClassificationReport when
+// a baseline is given.
+type ClassificationExposure struct {
+	Level     int                    `json:"Level"`
+	Name      string                 `json:"Name"`
+	Highest   bool                   `json:"Highest"`
+	Abilities []string               `json:"Abilities"`
+	Roles     []string               `json:"Roles"`
+	Groups    []string               `json:"Groups"`
+	Users     []string               `json:"Users"`
+	Since     *time.Time             `json:"Since,omitempty"`
+	Changes   *ClassificationChanges `json:"Changes,omitempty"`
+}
+
+// ClassificationChanges is what was added to and removed from a level since
+// the baseline: an ability added or removed may also have been reclassified.
+type ClassificationChanges struct {
+	AbilitiesAdded   []string `json:"AbilitiesAdded"`
+	AbilitiesRemoved []string `json:"AbilitiesRemoved"`
+	RolesAdded       []string `json:"RolesAdded"`
+	RolesRemoved     []string `json:"RolesRemoved"`
+	GroupsAdded      []string `json:"GroupsAdded"`
+	GroupsRemoved    []string `json:"GroupsRemoved"`
+	UsersAdded       []string `json:"UsersAdded"`
+	UsersRemoved     []string `json:"UsersRemoved"`
+}
+
+// Empty reports whether nothing changed.
+func (c ClassificationChanges) Empty() bool {
+	return len(c.AbilitiesAdded)+len(c.AbilitiesRemoved)+len(c.RolesAdded)+len(c.RolesRemoved)+
+		len(c.GroupsAdded)+len(c.GroupsRemoved)+len(c.UsersAdded)+len(c.UsersRemoved) == 0
+}
+
+// ClassificationReport returns the exposure of every DataClassification level
+// carried by a functional ability, highest level first. Users are HubIDs.
+// When baseline is not nil, each level also lists what changed since then
+// (taken at since); levels found only in the baseline are reported with
+// their removals.
+func ClassificationReport(current *State, baseline *State, since time.Time) []ClassificationExposure {
+	levels := classificationExposures(current)
+
+	var old map[int]ClassificationExposure
+	if baseline != nil {
+		old = classificationExposures(baseline)
+		for level := range old {
+			if _, ok := levels[level]; !ok {
+				levels[level] = ClassificationExposure{Level: level, Name: ClassificationName(level)}
+			}
+		}
+	}
+
+	highest := -1
+	for level, exposure := range levels {
+		if len(exposure.Users) > 0 && level > highest {
+			highest = level
+		}
+	}
+
+	report := make([]ClassificationExposure, 0, len(levels))
+	for level, exposure := range levels {
+		exposure.Highest = level == highest
+		if baseline != nil {
+			before := old[level]
+			changes := &ClassificationChanges{}
+			changes.AbilitiesAdded, changes.AbilitiesRemoved = diffNames(before.Abilities, exposure.Abilities)
+			changes.RolesAdded, changes.RolesRemoved = diffNames(before.Roles, exposure.Roles)
+			changes.GroupsAdded, changes.GroupsRemoved = diffNames(before.Groups, exposure.Groups)
+			changes.UsersAdded, changes.UsersRemoved = diffNames(before.Users, exposure.Users)
+			exposure.Since = &since
+			exposure.Changes = changes
+		}
+		report = append(report, exposure)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Level > report[j].Level
+	})
+	return report
+}
+
+// classificationExposures computes the exposure of each level in the state.
+func classificationExposures(state *State) map[int]ClassificationExposure {
+	levels := make(map[int]ClassificationExposure)
+	add := func(list *[]string, name string) {
+		if !containsFold(*list, name) {
+			*list = append(*list, name)
+		}
+	}
+
+	for _, ability := range state.FunctionalAbilities {
+		exposure := levels[ability.DataClassification]
+		exposure.Level, exposure.Name = ability.DataClassification, ClassificationName(ability.DataClassification)
+		add(&exposure.Abilities, ability.Name)
+		levels[ability.DataClassification] = exposure
+	}
+
+	roleLevels := make(map[string][]int)
+	for _, role := range state.Roles {
+		for _, ability := range state.RoleAbilities(role) {
+			exposure := levels[ability.DataClassification]
+			add(&exposure.Roles, role.Name)
+			levels[ability.DataClassification] = exposure
+			roleLevels[role.Id] = append(roleLevels[role.Id], ability.DataClassification)
+		}
+	}
+
+	for _, group := range state.Groups {
+		for _, binding := range group.Roles {
+			for _, level := range roleLevels[binding.RoleId] {
+				exposure := levels[level]
+				add(&exposure.Groups, group.Group.Name)
+				for _, user := range group.Members {
+					add(&exposure.Users, user.IdAtSourceSystem)
+				}
+				levels[level] = exposure
+			}
+		}
+	}
+
+	for level, exposure := range levels {
+		sortFold(exposure.Abilities)
+		sortFold(exposure.Roles)
+		sortFold(exposure.Groups)
+		sortFold(exposure.Users)
+		levels[level] = exposure
+	}
+	return levels
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CleanupAllowlistFile lists the HubIDs 'cleanup inactive' must never remove,
+// unless another file is given. It is optional.
+const CleanupAllowlistFile = "~/.c3po/cleanup-allowlist.txt"
+
+// Why a member is considered gone, reported in InactiveMember.Reason.
+const (
+	InactiveReasonInactive   = "inactive"
+	InactiveReasonUnresolved = "not found"
+)
+
+// InactiveMember is a group membership of a user who is inactive or who no
+// longer resolves in Keystone. Status and Error are set once removal was
+// attempted, with the MemberResult statuses.
+type InactiveMember struct {
+	Group        string `json:"Group"`
+	GroupId      string `json:"GroupId"`
+	SourceSystem string `json:"SourceSystem"`
+	User         User   `json:"User"`
+	Reason       string `json:"Reason"`
+	Allowlisted  bool   `json:"Allowlisted"`
+	Status       string `json:"Status,omitempty"`
+	Error        string `json:"Error,omitempty"`
+}
+
+// FindInactiveMembers scans every group membership for users whose IsActive
+// is false. With resolve, every other member is also looked up by HubID (once
+// per user) to catch users deleted or deactivated since the membership was
+// listed. HubIDs in allowlist (lowercased) are marked Allowlisted. Results are
+// sorted by group, source system and HubID.
+func (c *Client) FindInactiveMembers(state *State, allowlist map[string]bool, resolve bool) ([]InactiveMember, error) {
+	reasons := make(map[string]string)
+	reason := func(user User) (string, error) {
+		hubid := strings.TrimSpace(user.IdAtSourceSystem)
+		if hubid == "" {
+			return InactiveReasonUnresolved, nil
+		}
+		if !user.IsActive {
+			return InactiveReasonInactive, nil
+		}
+		if !resolve {
+			return "", nil
+		}
+
+		key := strings.ToLower(hubid)
+		if r, ok := reasons[key]; ok {
+			return r, nil
+		}
+		current, err := c.LookupUser(hubid)
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			reasons[key] = InactiveReasonUnresolved
+		case err != nil:
+			return "", err
+		case !current.IsActive:
+			reasons[key] = InactiveReasonInactive
+		default:
+			reasons[key] = ""
+		}
+		return reasons[key], nil
+	}
+
+	var members []InactiveMember
+	for _, group := range state.Groups {
+		for _, user := range group.Members {
+			r, err := reason(user)
+			if err != nil {
+				return nil, err
+			}
+			if r == "" {
+				continue
+			}
+			members = append(members, InactiveMember{
+				Group:        group.Group.Name,
+				GroupId:      group.Group.Id,
+				SourceSystem: user.SourceSystemName,
+				User:         user,
+				Reason:       r,
+				Allowlisted:  allowlist[strings.ToLower(strings.TrimSpace(user.IdAtSourceSystem))],
+			})
+		}
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if ka, kb := strings.ToLower(a.Group), strings.ToLower(b.Group); ka != kb {
+			return ka < kb
+		}
+		if ka, kb := strings.ToLower(a.SourceSystem), strings.ToLower(b.SourceSystem); ka != kb {
+			return ka < kb
+		}
+		return strings.ToLower(a.User.IdAtSourceSystem) < strings.ToLower(b.User.IdAtSourceSystem)
+	})
+	return members, nil
+}
+
+// RemoveInactiveMembers removes every membership that is not allowlisted,
+// calling progress after each one, and returns the members with their Status
+// (MemberWouldRemove under --dry-run).
+func (c *Client) RemoveInactiveMembers(members []InactiveMember, progress func(InactiveMember)) []InactiveMember {
+	for i, member := range members {
+		if member.Allowlisted {
+			continue
+		}
+		if member.User.Id == "" {
+			member.Status, member.Error = MemberFailed, fmt.Sprintf("membership of %q has no Keystone user id", member.User.IdAtSourceSystem)
+		} else if err := c.RemoveGroupMember(member.GroupId, member.User.Id); err != nil {
+			member.Status, member.Error = MemberFailed, err.Error()
+		} else {
+			member.Status = MemberRemoved
+			if c.dryRun {
+				member.Status = MemberWouldRemove
+			}
+		}
+		members[i] = member
+		if progress != nil {
+			progress(member)
+		}
+	}
+	return members
+}
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"bytes"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"io/ioutil"
 )
@@ -15,6 +18,7 @@ import (
 type Client struct {
 	client *http.Client
 
+	c3poEnvironment string
 	c3poInstance string
 	c3poApplicationID string
 	c3poUsername string
@@ -22,6 +26,10 @@ type Client struct {
 	c3poAccessToken string
 
 	debug bool
+
+	// dryRun prints mutating requests to dryRunOutput instead of sending them.
+	dryRun       bool
+	dryRunOutput io.Writer
 }
 
 const (
@@ -33,13 +41,23 @@ const (
 
 // NewClient - Creates a new client and returns an error if it fails
 func NewClient(c3poUsername, c3poPassword, c3poAccessToken string, debug bool) (*Client, error) {
+	return NewClientForEnvironment(DefaultEnvironment(), c3poUsername, c3poPassword, c3poAccessToken, debug)
+}
+
+// NewClientForEnvironment - Creates a new client for a Keystone environment
+// (see LoadEnvironments) and returns an error if it fails
+func NewClientForEnvironment(env Environment, c3poUsername, c3poPassword, c3poAccessToken string, debug bool) (*Client, error) {
 	if c3poUsername == "" || c3poPassword == "" {
 		return nil, fmt.Errorf("username or password cannot be empty")
 	}
+	if env.APIServer == "" || env.ApplicationID == "" {
+		return nil, fmt.Errorf("environment %q needs an API server and an application id", env.Name)
+	}
 
 	c := &Client{
-		c3poInstance:      keystoneAPIServer,
-		c3poApplicationID: keystoneApplicationID,
+		c3poEnvironment:   env.Name,
+		c3poInstance:      strings.TrimSuffix(env.APIServer, "/"),
+		c3poApplicationID: env.ApplicationID,
 		c3poUsername:      c3poUsername,
 		c3poPassword:      c3poPassword,
 		c3poAccessToken:   c3poAccessToken,
@@ -171,3 +189,76 @@ func extractFirstAndLast(input string) (first, last string) {
 
     return first, last
 }
+
+// keystone sends an authorized request to the Keystone API, encoding in (when
+// not nil) as the JSON body and decoding the JSON response into out (when not nil).
+func (c *Client) keystone(method string, apiEndpoint string, apiQueryString string, in interface{}, out interface{}) error {
+	apiHeader := make(map[string][]string)
+	apiHeader["Authorization"] = []string{"Bearer " + c.c3poAccessToken}
+	apiHeader["Accept"] = []string{"application/json"}
+
+	if c.dryRun && strings.ToUpper(method) != "GET" {
+		return c.printDryRun(method, apiEndpoint, apiQueryString, in)
+	}
+
+	var apiBody io.Reader
+	if in != nil {
+		jsonBody, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		apiHeader["Content-Type"] = []string{"application/json"}
+		apiBody = bytes.NewReader(jsonBody)
+	}
+
+	respBytes, statusCode, err := c.API(method, apiHeader, apiEndpoint, apiQueryString, apiBody)
+	if err != nil {
+		return err
+	}
+
+	if c.debug {
+		fmt.Println(method, apiEndpoint, "=> Response as string:", string(respBytes))
+	}
+
+	if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("API request %s %s failed with status code: %d", strings.ToUpper(method), apiEndpoint, statusCode)
+	}
+
+	if out == nil || len(bytes.TrimSpace(respBytes)) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(respBytes, out)
+}
+
+// SetDryRun makes every mutating Keystone request print what would be sent
+// (to stderr, so that stdout only holds results) instead of sending it. Reads
+// are still performed.
+func (c *Client) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+	if c.dryRunOutput == nil {
+		c.dryRunOutput = os.Stderr
+	}
+}
+
+// DryRun reports whether the client is in dry-run mode.
+func (c *Client) DryRun() bool {
+	return c.dryRun
+}
+
+func (c *Client) printDryRun(method string, apiEndpoint string, apiQueryString string, in interface{}) error {
+	apiURL := c.c3poInstance + "/" + apiEndpoint
+	if apiQueryString != "" {
+		apiURL = apiURL + "?" + apiQueryString
+	}
+
+	fmt.Fprintf(c.dryRunOutput, "[dry-run] %s %s\n", strings.ToUpper(method), apiURL)
+	if in != nil {
+		jsonBody, err := json.MarshalIndent(in, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.dryRunOutput, string(jsonBody))
+	}
+	return nil
+}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"os/user"
+	"path/filepath"
 	"encoding/json"
 	"time"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"regexp"
 )
@@ -30,6 +33,13 @@ type Role struct {
         LastUpdate             string      `json:"LastUpdate"`
 }
 
+type GroupRole struct {
+    Id                     string       `json:"Id"`
+    GroupId                string       `json:"GroupId"`
+    RoleId                 string       `json:"RoleId"`
+    LastUpdate             string       `json:"LastUpdate"`
+}
+
 type GroupAttributes struct {
     AttributeId            string       `json:"AttributeId"`
     AttributeName          string       `json:"AttributeName"`
@@ -73,6 +83,12 @@ const (
 
 // GetAbsolutePath takes a path string and returns its absolute path.
 func (c *Client) GetAbsolutePath(path string) (string, error) {
+    return AbsolutePath(path)
+}
+
+// AbsolutePath expands a leading '~' and returns the absolute path. It does
+// not need a client, for commands that only work on local files.
+func AbsolutePath(path string) (string, error) {
     // Expand the '~' if used
     if len(path) > 0 && path[:1] == "~" {
         usr, err := user.Current()
@@ -92,11 +108,20 @@ func (c *Client) GetAbsolutePath(path string) (string, error) {
 }
 
 func (c *Client) removeC3POPrefixes(input string) string {
+	return removeC3POPrefixes(input)
+}
+
+func (c *Client) reduceSpaces(input string) string {
+	return reduceSpaces(input)
+}
+
+// removeC3POPrefixes drops a leading "C3PO -" or "C3PO" prefix, in any case.
+func removeC3POPrefixes(input string) string {
 	// Define the prefixes to remove
-	prefixes := []string{"C3PO -", "C3PO-", "C3PO", "C3PO\\s+-"}
+	prefixes := []string{"C3PO\\s*-", "C3PO"}
 
 	// Construct the regular expression pattern
-	pattern := "(" + strings.Join(prefixes, "|") + ")\\s*$"
+	pattern := "(?i)^\\s*(" + strings.Join(prefixes, "|") + ")\\s*"
 
 	// Compile the regular expression
 	regexpPattern := regexp.MustCompile(pattern)
@@ -107,7 +132,8 @@ func (c *Client) removeC3POPrefixes(input string) string {
 	return strings.TrimSpace(result)
 }
 
-func (c *Client) reduceSpaces(input string) string {
+// reduceSpaces replaces runs of whitespace with a single space.
+func reduceSpaces(input string) string {
 	// Define the regular expression pattern to match two or more spaces
 	regex := regexp.MustCompile(`\s{2,}`)
 
@@ -125,40 +151,271 @@ func (c *Client) SortByRoleID(roles []Role) {
 }
 
 // Print roles neatly
-func (c *Client) PrintRoles(roles []Role) {
-	fmt.Println("===========================================\n")
-	totalRoles := len(roles)
-	for i, role := range roles {
-		index := i + 1
-		if ( i > 0 ) {
-			fmt.Println("-------------------------------------------")
-		}
-		fmt.Printf("Role %d/%d: %s\n", index, totalRoles, role.Name)
-		fmt.Println("\tApplicationId:", role.ApplicationId)
-		fmt.Println("\tDescription:", role.Description)
-		fmt.Println("\tConditionalExpression:", role.ConditionalExpression)
-		// Add other fields here
-		fmt.Println()
+func (c *Client) PrintRoles(roles []Role) error {
+	return printRoles(roles, nil)
+}
+
+// PrintRoleMatches prints matched roles like PrintRoles, with their score.
+func (c *Client) PrintRoleMatches(matches []RoleMatch) error {
+	roles := make([]Role, len(matches))
+	scores := make([]float64, len(matches))
+	for i, match := range matches {
+		roles[i], scores[i] = match.Role, match.Score
 	}
+	return printRoles(roles, scores)
 }
 
-// Print groups neatly
-func (c *Client) PrintGroups(groups []Group) {
-        for _, group := range groups {
-                //fmt.Println("Group ID:", group.Id)
-                fmt.Println("Name: " + group.Name)
-                //fmt.Println("Description:", group.Description)
-                //fmt.Println("Last Update:", group.LastUpdate)
-                // Print other fields as needed
-                //fmt.Println("----------------------------------")
-        }
+// printRoles prints roles, with a Score column when scores is not nil.
+func printRoles(roles []Role, scores []float64) error {
+	columns := []Column{
+		{Name: "Name", Value: func(v interface{}) string { return v.(Role).Name }},
+		{Name: "Description", Value: func(v interface{}) string { return v.(Role).Description }},
+		{Name: "ConditionalExpression", Value: func(v interface{}) string { return FormatExpression(v.(Role).ConditionalExpression) }},
+		{Name: "FunctionalAbilities", Value: func(v interface{}) string { return strings.Join(RoleAbilityIds(v.(Role)), ",") }},
+		{Name: "Id", Value: func(v interface{}) string { return v.(Role).Id }},
+		{Name: "LastUpdate", Value: func(v interface{}) string { return v.(Role).LastUpdate }},
+	}
+	var items interface{} = roles
+	if scores != nil {
+		items, columns = scoredItems(roles, scores, columns)
+	}
+
+	return PrintItems(items, columns, func() {
+		fmt.Println("===========================================")
+		fmt.Println()
+		totalRoles := len(roles)
+		for i, role := range roles {
+			index := i + 1
+			if ( i > 0 ) {
+				fmt.Println("-------------------------------------------")
+			}
+			fmt.Printf("Role %d/%d: %s\n", index, totalRoles, role.Name)
+			if scores != nil {
+				fmt.Printf("\tScore: %.2f\n", scores[i])
+			}
+			fmt.Println("\tApplicationId:", role.ApplicationId)
+			fmt.Println("\tDescription:", role.Description)
+			fmt.Println("\tConditionalExpression:", FormatExpression(role.ConditionalExpression))
+			// Add other fields here
+			fmt.Println()
+		}
+	})
 }
 
 // Print groups neatly
-func (c *Client) PrintFunctionalAbilities(functionalabilities []FunctionalAbilities) {
-        for _, functionalability := range functionalabilities {
-                fmt.Println("Name: " + functionalability.Name)
-        }
+func (c *Client) PrintGroups(groups []Group) error {
+	return printGroups(groups, nil)
+}
+
+// PrintGroupMatches prints matched groups like PrintGroups, with their score.
+func (c *Client) PrintGroupMatches(matches []GroupMatch) error {
+	groups := make([]Group, len(matches))
+	scores := make([]float64, len(matches))
+	for i, match := range matches {
+		groups[i], scores[i] = match.Group, match.Score
+	}
+	return printGroups(groups, scores)
+}
+
+// printGroups prints groups, with a Score column when scores is not nil.
+func printGroups(groups []Group, scores []float64) error {
+	columns := []Column{
+		{Name: "Name", Value: func(v interface{}) string { return v.(Group).Name }},
+		{Name: "Id", Value: func(v interface{}) string { return v.(Group).Id }},
+		{Name: "LastUpdate", Value: func(v interface{}) string { return v.(Group).LastUpdate }},
+	}
+	var items interface{} = groups
+	if scores != nil {
+		items, columns = scoredItems(groups, scores, columns)
+	}
+
+	return PrintItems(items, columns, func() {
+	        for i, group := range groups {
+	                //fmt.Println("Group ID:", group.Id)
+	                if scores != nil {
+	                        fmt.Printf("%.2f\t", scores[i])
+	                }
+	                fmt.Println("Name: " + group.Name)
+	                //fmt.Println("Description:", group.Description)
+	                //fmt.Println("Last Update:", group.LastUpdate)
+	                // Print other fields as needed
+	                //fmt.Println("----------------------------------")
+	        }
+	})
+}
+
+// scoredItem is a role or group printed with its match score.
+type scoredItem struct {
+	Score float64
+	Item  interface{}
+}
+
+// MarshalJSON writes the item's own fields with Score added.
+func (s scoredItem) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(s.Item)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["Score"] = s.Score
+	return json.Marshal(fields)
+}
+
+// scoredItems pairs each of items, a slice, with its score and returns the
+// columns to print them, a Score column first.
+func scoredItems(items interface{}, scores []float64, columns []Column) ([]scoredItem, []Column) {
+	list := itemList(items)
+	scored := make([]scoredItem, len(list))
+	for i, item := range list {
+		scored[i] = scoredItem{Score: scores[i], Item: item}
+	}
+
+	result := []Column{{Name: "Score", Value: func(v interface{}) string { return fmt.Sprintf("%.2f", v.(scoredItem).Score) }}}
+	for _, column := range columns {
+		value := column.Value
+		result = append(result, Column{Name: column.Name, Value: func(v interface{}) string { return value(v.(scoredItem).Item) }})
+	}
+	return scored, result
+}
+
+// Print users neatly
+func (c *Client) PrintUsers(users []User) error {
+	columns := []Column{
+		{Name: "IdAtSourceSystem", Value: func(v interface{}) string { return v.(User).IdAtSourceSystem }},
+		{Name: "CommonName", Value: func(v interface{}) string { return v.(User).CommonName }},
+		{Name: "Email", Value: func(v interface{}) string { return v.(User).Email }},
+		{Name: "IsActive", Value: func(v interface{}) string { return fmt.Sprint(v.(User).IsActive) }},
+		{Name: "SourceSystemName", Value: func(v interface{}) string { return v.(User).SourceSystemName }},
+		{Name: "Id", Value: func(v interface{}) string { return v.(User).Id }},
+	}
+
+	return PrintItems(users, columns, func() {
+		for _, user := range users {
+			active := "active"
+			if !user.IsActive {
+				active = "INACTIVE"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", user.IdAtSourceSystem, user.CommonName, user.Email, active, user.SourceSystemName)
+		}
+	})
+}
+
+// Print the outcome of a bulk membership change
+func (c *Client) PrintMemberResults(results []MemberResult) error {
+	columns := []Column{
+		{Name: "HubID", Value: func(v interface{}) string { return v.(MemberResult).HubID }},
+		{Name: "Status", Value: func(v interface{}) string { return v.(MemberResult).Status }},
+		{Name: "Error", Value: func(v interface{}) string { return v.(MemberResult).ErrorMessage() }},
+		{Name: "CommonName", Value: func(v interface{}) string { return v.(MemberResult).User.CommonName }},
+	}
+
+	return PrintItems(results, columns, func() {
+		for _, result := range results {
+			if result.Err != nil {
+				fmt.Printf("%s\t%s: %v\n", result.HubID, result.Status, result.Err)
+			} else {
+				fmt.Printf("%s\t%s\n", result.HubID, result.Status)
+			}
+		}
+	})
+}
+
+// Print a plan the way Terraform does: + create, ~ update, - delete
+func (c *Client) PrintPlan(plan *Plan) error {
+	columns := []Column{
+		{Name: "Action", Value: func(v interface{}) string { return string(v.(Change).Action) }},
+		{Name: "Kind", Value: func(v interface{}) string { return v.(Change).Kind }},
+		{Name: "Target", Value: func(v interface{}) string { return v.(Change).Target }},
+		{Name: "Details", Value: func(v interface{}) string { return strings.Join(v.(Change).Details, "; ") }},
+	}
+
+	return PrintItems(plan.Changes, columns, func() {
+		symbols := map[ChangeAction]string{ChangeCreate: "+", ChangeUpdate: "~", ChangeDelete: "-"}
+		for _, change := range plan.Changes {
+			fmt.Printf("  %s %s %q\n", symbols[change.Action], change.Kind, change.Target)
+			for _, detail := range change.Details {
+				fmt.Println("        " + detail)
+			}
+		}
+		created, updated, deleted := CountChanges(plan.Changes)
+		fmt.Printf("\nPlan: %d to create, %d to update, %d to delete.\n", created, updated, deleted)
+	})
+}
+
+// Print the differences between two states: + added, ~ changed, - removed
+func PrintDifferences(diffs []Difference) error {
+	columns := []Column{
+		{Name: "Change", Value: func(v interface{}) string { return v.(Difference).Change }},
+		{Name: "Kind", Value: func(v interface{}) string { return v.(Difference).Kind }},
+		{Name: "Name", Value: func(v interface{}) string { return v.(Difference).Name }},
+		{Name: "Details", Value: func(v interface{}) string { return strings.Join(v.(Difference).Details, "; ") }},
+	}
+
+	return PrintItems(diffs, columns, func() {
+		symbols := map[string]string{DiffAdded: "+", DiffChanged: "~", DiffRemoved: "-"}
+		for _, diff := range diffs {
+			fmt.Printf("  %s %s %q\n", symbols[diff.Change], diff.Kind, diff.Name)
+			for _, detail := range diff.Details {
+				fmt.Println("        " + detail)
+			}
+		}
+	})
+}
+
+// Print group attributes neatly
+func (c *Client) PrintGroupAttributes(attrs []GroupAttributes) error {
+	columns := []Column{
+		{Name: "AttributeName", Value: func(v interface{}) string { return v.(GroupAttributes).AttributeName }},
+		{Name: "AttributeValue", Value: func(v interface{}) string { return v.(GroupAttributes).AttributeValue }},
+		{Name: "UsageType", Value: func(v interface{}) string { return UsageTypeName(v.(GroupAttributes).UsageType) }},
+		{Name: "GroupRoleId", Value: func(v interface{}) string { return v.(GroupAttributes).GroupRoleId }},
+		{Name: "Id", Value: func(v interface{}) string { return v.(GroupAttributes).Id }},
+		{Name: "LastUpdate", Value: func(v interface{}) string { return v.(GroupAttributes).LastUpdate }},
+	}
+
+	return PrintItems(attrs, columns, func() {
+		for _, attr := range attrs {
+			target := "group"
+			if attr.GroupRoleId != "" {
+				target = "group-role " + attr.GroupRoleId
+			}
+			fmt.Printf("%s = %s\t(%s, usage type %s)\n", attr.AttributeName, attr.AttributeValue, target, UsageTypeName(attr.UsageType))
+		}
+	})
+}
+
+// Print functional abilities neatly
+func (c *Client) PrintFunctionalAbilities(functionalabilities []FunctionalAbilities) error {
+	columns := []Column{
+		{Name: "Name", Value: func(v interface{}) string { return v.(FunctionalAbilities).Name }},
+		{Name: "Description", Value: func(v interface{}) string { return v.(FunctionalAbilities).Description }},
+		{Name: "DataClassification", Value: func(v interface{}) string { return fmt.Sprint(v.(FunctionalAbilities).DataClassification) }},
+		{Name: "SodRole", Value: func(v interface{}) string { return v.(FunctionalAbilities).SodRole }},
+		{Name: "EntityAccess", Value: func(v interface{}) string { return strings.Join(EntityAccess(v.(FunctionalAbilities)), "; ") }},
+		{Name: "Id", Value: func(v interface{}) string { return v.(FunctionalAbilities).Id }},
+		{Name: "LastUpdate", Value: func(v interface{}) string { return v.(FunctionalAbilities).LastUpdate }},
+	}
+
+	return PrintItems(functionalabilities, columns, func() {
+		totalAbilities := len(functionalabilities)
+		for i, functionalability := range functionalabilities {
+			if i > 0 {
+				fmt.Println("-------------------------------------------")
+			}
+			fmt.Printf("Functional Ability %d/%d: %s\n", i+1, totalAbilities, functionalability.Name)
+			fmt.Println("\tDescription:", functionalability.Description)
+			fmt.Printf("\tDataClassification: %d (%s)\n", functionalability.DataClassification, ClassificationName(functionalability.DataClassification))
+			fmt.Println("\tSodRole:", functionalability.SodRole)
+			fmt.Println("\tEntityAccess:")
+			for _, entity := range EntityAccess(functionalability) {
+				fmt.Println("\t\t" + entity)
+			}
+			fmt.Println()
+		}
+	})
 }
 
 func (c *Client) GetAccessToken() (string, error) {
@@ -248,7 +505,12 @@ func (c *Client) GetAccessToken() (string, error) {
 
 	c.c3poAccessToken = token
 
-	absAccessTokenFile, err1 := c.GetAbsolutePath(accessTokenFile)
+	tokenFile := accessTokenFile
+	if c.c3poEnvironment != keystoneTarget {
+		tokenFile = accessTokenFile + "-" + c.c3poEnvironment
+	}
+
+	absAccessTokenFile, err1 := c.GetAbsolutePath(tokenFile)
         if err1 != nil {
 		return "", fmt.Errorf("Error: %w", err1)
         }
@@ -289,110 +551,154 @@ func (c *Client) IsTokenFileValid(tokenFilePath string) (bool, error) {
 
 }
 
-func (c *Client) GetGroup(rolename string, exactmatched bool) ([]map[string]interface{}, error) {
-	// FilteredItems to store the filtered roles
-	var filteredItems []map[string]interface{}
+// RoleMatch is a role found by FindRoles along with how well its name matched.
+// Score is only set for fuzzy matches.
+type RoleMatch struct {
+	Role  Role
+	Rank  MatchRank
+	Score float64
+}
 
-	requestedGroupEscaped := url.QueryEscape("C3PO - " + rolename)
-	if c.debug {
-		fmt.Println("Requested Group Escaped:", requestedGroupEscaped)
-	}
+// GroupMatch is a group found by FindGroups along with how well its name matched.
+// Score is only set for fuzzy matches.
+type GroupMatch struct {
+	Group Group
+	Rank  MatchRank
+	Score float64
+}
 
-	KeyStoneAPIPath := "adminservice/keystone/v1/group?groupName=" + requestedGroupEscaped
-	if c.debug {
-		fmt.Println("KeyStone API Path:", KeyStoneAPIPath)
+// ListRoles returns every role defined for the application.
+func (c *Client) ListRoles() ([]Role, error) {
+	var roles []Role
+	err := c.keystone("GET", "adminservice/keystone/v1/application/"+c.c3poApplicationID+"/role", "", nil, &roles)
+	if err != nil {
+		return nil, err
 	}
+	return roles, nil
+}
 
-	REQ_METHOD := "GET"
-	apiHeader := make(map[string][]string)
-	apiHeader["Authorization"] = []string{"Bearer " + c.c3poAccessToken}
-	apiHeader["Accept"] = []string{"application/json"}
-
-	respBytes, statusCode, err := c.API(REQ_METHOD, apiHeader, KeyStoneAPIPath, "", nil)
+// ListGroups returns every group whose name starts with "C3PO - ".
+func (c *Client) ListGroups() ([]Group, error) {
+	var groups []Group
+	err := c.keystone("GET", "adminservice/keystone/v1/group", "groupName="+url.QueryEscape("C3PO - "), nil, &groups)
 	if err != nil {
 		return nil, err
 	}
+	return groups, nil
+}
 
-	if c.debug {
-		fmt.Println("Get Role => Response as string:", string(respBytes))
+// FindRoles returns the roles whose name matches rolename, best match first.
+func (c *Client) FindRoles(rolename string, mode MatchMode, limit int) ([]RoleMatch, error) {
+	roles, err := c.ListRoles()
+	if err != nil {
+		return nil, err
 	}
+	return c.MatchRoles(roles, rolename, mode, limit)
+}
 
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status code: %d", statusCode)
+// MatchRoles returns the roles among roles whose name matches rolename, best
+// match first.
+func (c *Client) MatchRoles(roles []Role, rolename string, mode MatchMode, limit int) ([]RoleMatch, error) {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
 	}
 
-	var roles []map[string]interface{}
-	if err := json.Unmarshal(respBytes, &roles); err != nil {
+	matches, err := c.matchNames(names, rolename, mode, limit)
+	if err != nil {
 		return nil, err
 	}
 
-	if exactmatched {
-		requestedGroupLower := strings.ToLower("C3PO - " + rolename)
-		re := regexp.MustCompile(`[()]`)
-		requestedGroupLower = re.ReplaceAllStringFunc(requestedGroupLower, func(s string) string {
-			return "\\" + s
-		})
-		if c.debug {
-			fmt.Println("Requested Group Lower:", requestedGroupLower)
-		}
-
-		pattern := fmt.Sprintf("^%s$", regexp.QuoteMeta(requestedGroupLower))
+	return roleMatches(roles, matches), nil
+}
 
-		for _, item := range roles {
-			name, ok := item["Name"].(string)
-			if !ok {
-				continue
-			}
-			if matched, _ := regexp.MatchString(pattern, strings.ToLower(name)); matched {
-				filteredItems = append(filteredItems, item)
-			}
-		}
-	} else {
-		roleNameLower := strings.ToLower(rolename)
-		for _, item := range roles {
-			name, ok := item["Name"].(string)
-			if !ok {
-				continue
-			}
-			if strings.Contains(strings.ToLower(name), roleNameLower) {
-				filteredItems = append(filteredItems, item)
-				break
-			}
-		}
+// SuggestRoles returns up to limit roles among roles whose name is close to
+// rolename, for "did you mean" hints when nothing matched.
+func (c *Client) SuggestRoles(roles []Role, rolename string, limit int) []RoleMatch {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
 	}
 
-	return filteredItems, nil
+	return roleMatches(roles, c.fuzzyMatchNames(names, rolename, suggestThreshold, limit))
 }
 
-func (c *Client) GetRole(rolename string, exactmatched bool) ([]map[string]interface{}, error) {
-	// FilteredItems to store the filtered roles
-	var filteredItems []map[string]interface{}
+func roleMatches(roles []Role, matches []nameMatch) []RoleMatch {
+	result := make([]RoleMatch, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, RoleMatch{Role: roles[m.Index], Rank: m.Rank, Score: m.Score})
+	}
+	return result
+}
 
-	KeyStoneAPIPath := "adminservice/keystone/v1/application/" + c.c3poApplicationID + "/role"
-	if c.debug {
-		fmt.Println("KeyStone API Path:", KeyStoneAPIPath)
+// FindGroups returns the C3PO groups whose name matches groupname, best match
+// first. The "C3PO - " prefix is ignored on both sides.
+func (c *Client) FindGroups(groupname string, mode MatchMode, limit int) ([]GroupMatch, error) {
+	groups, err := c.ListGroups()
+	if err != nil {
+		return nil, err
 	}
+	return c.MatchGroups(groups, groupname, mode, limit)
+}
 
-	REQ_METHOD := "GET"
-	apiHeader := make(map[string][]string)
-	apiHeader["Authorization"] = []string{"Bearer " + c.c3poAccessToken}
-	apiHeader["Accept"] = []string{"application/json"}
+// MatchGroups returns the groups among groups whose name matches groupname,
+// best match first. The "C3PO - " prefix is ignored on both sides.
+func (c *Client) MatchGroups(groups []Group, groupname string, mode MatchMode, limit int) ([]GroupMatch, error) {
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = c.removeC3POPrefixes(group.Name)
+	}
+
+	pattern := groupname
+	if mode == MatchAuto || mode == MatchExact {
+		pattern = c.removeC3POPrefixes(groupname)
+	}
 
-	respBytes, statusCode, err := c.API(REQ_METHOD, apiHeader, KeyStoneAPIPath, "", nil)
+	matches, err := c.matchNames(names, pattern, mode, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	if c.debug {
-		fmt.Println("Get Role => Response as string:", string(respBytes))
+	return groupMatches(groups, matches), nil
+}
+
+// SuggestGroups returns up to limit groups among groups whose name is close to
+// groupname, for "did you mean" hints when nothing matched.
+func (c *Client) SuggestGroups(groups []Group, groupname string, limit int) []GroupMatch {
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = group.Name
 	}
 
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status code: %d", statusCode)
+	return groupMatches(groups, c.fuzzyMatchNames(names, groupname, suggestThreshold, limit))
+}
+
+func groupMatches(groups []Group, matches []nameMatch) []GroupMatch {
+	result := make([]GroupMatch, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, GroupMatch{Group: groups[m.Index], Rank: m.Rank, Score: m.Score})
 	}
+	return result
+}
+
+// GetGroup returns the raw C3PO groups matching rolename. With exactmatched
+// false every group containing rolename is returned, best match first.
+func (c *Client) GetGroup(rolename string, exactmatched bool) ([]map[string]interface{}, error) {
+	var groups []map[string]interface{}
+	err := c.keystone("GET", "adminservice/keystone/v1/group", "groupName="+url.QueryEscape("C3PO - "), nil, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.filterByName(groups, rolename, exactmatched, c.removeC3POPrefixes)
+}
 
+// GetRole returns the raw roles matching rolename. With exactmatched false
+// every role containing rolename is returned, best match first.
+func (c *Client) GetRole(rolename string, exactmatched bool) ([]map[string]interface{}, error) {
 	var roles []map[string]interface{}
-	if err := json.Unmarshal(respBytes, &roles); err != nil {
+	err := c.keystone("GET", "adminservice/keystone/v1/application/"+c.c3poApplicationID+"/role", "", nil, &roles)
+	if err != nil {
 		return nil, err
 	}
 
@@ -400,40 +706,34 @@ func (c *Client) GetRole(rolename string, exactmatched bool) ([]map[string]inter
 		fmt.Println(roles)
 	}
 
-	roleNameLower := strings.ToLower(rolename)
-	if exactmatched {
-		roleNameLower := strings.ToLower(rolename)
-		re := regexp.MustCompile(`[()]`)
-		roleNameLower = re.ReplaceAllStringFunc(roleNameLower, func(s string) string {
-			return "\\" + s
-		})
-		if c.debug {
-			fmt.Println("Requested Role Lower:", roleNameLower)
-		}
+	return c.filterByName(roles, rolename, exactmatched, func(name string) string { return name })
+}
 
-		pattern := fmt.Sprintf("^%s$", regexp.QuoteMeta(roleNameLower))
+func (c *Client) filterByName(items []map[string]interface{}, pattern string, exactmatched bool, normalize func(string) string) ([]map[string]interface{}, error) {
+	mode := MatchAuto
+	if exactmatched {
+		mode = MatchExact
+	}
 
-		for _, item := range roles {
-			name, ok := item["Name"].(string)
-			if !ok {
-				continue
-			}
-			if matched, _ := regexp.MatchString(pattern, strings.ToLower(name)); matched {
-				filteredItems = append(filteredItems, item)
-			}
-		}
-	} else {
-		for _, item := range roles {
-			name, ok := item["Name"].(string)
-			if !ok {
-				continue
-			}
-			if strings.Contains(strings.ToLower(name), roleNameLower) {
-				filteredItems = append(filteredItems, item)
-				break
-			}
+	var named []map[string]interface{}
+	var names []string
+	for _, item := range items {
+		name, ok := item["Name"].(string)
+		if !ok {
+			continue
 		}
+		named = append(named, item)
+		names = append(names, normalize(name))
 	}
 
+	matches, err := c.matchNames(names, normalize(pattern), mode, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredItems []map[string]interface{}
+	for _, m := range matches {
+		filteredItems = append(filteredItems, named[m.Index])
+	}
 	return filteredItems, nil
 }
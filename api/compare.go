@@ -0,0 +1,169 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CompareManifests reports how the roles, functional abilities and group role
+// bindings of to differ from from. Objects are matched by name, since ids differ
+// between environments. Added means only in from, removed means only in to.
+// Group members and attributes are environment specific and not compared.
+func CompareManifests(from *Manifest, to *Manifest) []Difference {
+	var diffs []Difference
+
+	toRoles := make(map[string]ManifestRole)
+	for _, role := range to.Roles {
+		toRoles[strings.ToLower(role.Name)] = role
+	}
+	for _, role := range from.Roles {
+		key := strings.ToLower(role.Name)
+		target, ok := toRoles[key]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindRole, Name: role.Name, Change: DiffAdded, Details: roleDetails(role)})
+			continue
+		}
+		delete(toRoles, key)
+
+		var details []string
+		details = appendField(details, "description", target.Description, role.Description)
+		details = appendField(details, "conditionalExpression", target.ConditionalExpression, role.ConditionalExpression)
+		added, removed := diffNames(target.FunctionalAbilities, role.FunctionalAbilities)
+		for _, name := range added {
+			details = append(details, "+ functional ability "+name)
+		}
+		for _, name := range removed {
+			details = append(details, "- functional ability "+name)
+		}
+		if len(details) > 0 {
+			diffs = append(diffs, Difference{Kind: KindRole, Name: role.Name, Change: DiffChanged, Details: details})
+		}
+	}
+	for _, role := range toRoles {
+		diffs = append(diffs, Difference{Kind: KindRole, Name: role.Name, Change: DiffRemoved})
+	}
+
+	toAbilities := make(map[string]ManifestAbility)
+	for _, ability := range to.FunctionalAbilities {
+		toAbilities[strings.ToLower(ability.Name)] = ability
+	}
+	for _, ability := range from.FunctionalAbilities {
+		key := strings.ToLower(ability.Name)
+		target, ok := toAbilities[key]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindAbility, Name: ability.Name, Change: DiffAdded})
+			continue
+		}
+		delete(toAbilities, key)
+
+		var details []string
+		details = appendField(details, "description", target.Description, ability.Description)
+		details = appendField(details, "dataClassification", fmt.Sprint(target.DataClassification), fmt.Sprint(ability.DataClassification))
+		details = appendField(details, "sodRole", target.SodRole, ability.SodRole)
+		details = appendField(details, "entityAccess", jsonString(target.EntityAccess), jsonString(ability.EntityAccess))
+		if len(details) > 0 {
+			diffs = append(diffs, Difference{Kind: KindAbility, Name: ability.Name, Change: DiffChanged, Details: details})
+		}
+	}
+	for _, ability := range toAbilities {
+		diffs = append(diffs, Difference{Kind: KindAbility, Name: ability.Name, Change: DiffRemoved})
+	}
+
+	toGroups := make(map[string]ManifestGroup)
+	for _, group := range to.Groups {
+		toGroups[groupKey(group.Name)] = group
+	}
+	for _, group := range from.Groups {
+		key := groupKey(group.Name)
+		target, ok := toGroups[key]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindGroup, Name: group.Name, Change: DiffAdded})
+		}
+		delete(toGroups, key)
+
+		added, removed := diffNames(target.Roles, group.Roles)
+		for _, name := range added {
+			diffs = append(diffs, Difference{Kind: KindGroupRole, Name: group.Name + " / " + name, Change: DiffAdded})
+		}
+		for _, name := range removed {
+			diffs = append(diffs, Difference{Kind: KindGroupRole, Name: group.Name + " / " + name, Change: DiffRemoved})
+		}
+	}
+	for _, group := range toGroups {
+		diffs = append(diffs, Difference{Kind: KindGroup, Name: group.Name, Change: DiffRemoved})
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind < diffs[j].Kind
+		}
+		return strings.ToLower(diffs[i].Name) < strings.ToLower(diffs[j].Name)
+	})
+	return diffs
+}
+
+// PromotionManifest selects roles and group role bindings from a source
+// manifest, to be planned against the target environment's manifest. Group
+// role bindings are only added: a promoted group keeps the bindings it has in
+// the target. Group members and attributes are left unmanaged. With all set
+// every role and group is selected.
+func PromotionManifest(source *Manifest, target *Manifest, roles []string, groups []string, all bool) (*Manifest, error) {
+	promoted := &Manifest{}
+
+	// promote binds the group's source roles on top of its target ones.
+	promote := func(group ManifestGroup) ManifestGroup {
+		bound := []string{}
+		for _, have := range target.Groups {
+			if groupKey(have.Name) == groupKey(group.Name) {
+				bound = append(bound, have.Roles...)
+				break
+			}
+		}
+		for _, role := range group.Roles {
+			if !containsFold(bound, role) {
+				bound = append(bound, role)
+			}
+		}
+		return ManifestGroup{Name: group.Name, Roles: bound}
+	}
+
+	for _, name := range roles {
+		found := false
+		for _, role := range source.Roles {
+			if strings.EqualFold(role.Name, name) {
+				promoted.Roles = append(promoted.Roles, role)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("role %q not found in the source environment", name)
+		}
+	}
+
+	for _, name := range groups {
+		found := false
+		for _, group := range source.Groups {
+			if groupKey(group.Name) == groupKey(name) {
+				promoted.Groups = append(promoted.Groups, promote(group))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("group %q not found in the source environment", name)
+		}
+	}
+
+	if all {
+		promoted.Roles = append([]ManifestRole{}, source.Roles...)
+		promoted.Groups = nil
+		for _, group := range source.Groups {
+			promoted.Groups = append(promoted.Groups, promote(group))
+		}
+	}
+
+	promoted.Sort()
+	return promoted, nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CredentialsFile holds the HubID and password used to log in without a
+// prompt (cron, --yes automation). It must be readable by its owner only.
+//
+//	hubid: svc-c3po
+//	password: secret
+const CredentialsFile = "~/.c3po/credentials.yaml"
+
+// Credentials are a HubID and its password.
+type Credentials struct {
+	HubID    string `yaml:"hubid"`
+	Password string `yaml:"password"`
+}
+
+// LoadCredentials reads the credentials in path. It reports false, and no
+// error, when the file does not exist.
+func LoadCredentials(path string) (Credentials, bool, error) {
+	absPath, err := AbsolutePath(path)
+	if err != nil {
+		return Credentials{}, false, err
+	}
+
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) {
+		return Credentials{}, false, nil
+	} else if err != nil {
+		return Credentials{}, false, err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return Credentials{}, false, fmt.Errorf("%s can be read by other users: chmod 600 it", path)
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return Credentials{}, false, err
+	}
+
+	var credentials Credentials
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&credentials); err != nil {
+		return Credentials{}, false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	credentials.HubID = strings.TrimSpace(credentials.HubID)
+	if credentials.HubID == "" || credentials.Password == "" {
+		return Credentials{}, false, fmt.Errorf("%s needs hubid and password", path)
+	}
+	return credentials, true, nil
+}
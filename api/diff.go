@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// KindAbility is the Difference kind of functional abilities.
+const KindAbility = "functional ability"
+
+// Difference changes reported by DiffStates.
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// Difference is one thing that differs between two states.
+type Difference struct {
+	Kind    string
+	Name    string
+	Change  string
+	Details []string
+}
+
+// DiffStates reports what changed from before to after. Objects are matched by Id,
+// so renames show up as changes. An object whose LastUpdate moved without any
+// visible field change is still reported, since Keystone recorded an update.
+func DiffStates(before *State, after *State) []Difference {
+	var diffs []Difference
+
+	oldRoles := make(map[string]Role)
+	for _, role := range before.Roles {
+		oldRoles[role.Id] = role
+	}
+	for _, role := range after.Roles {
+		prev, ok := oldRoles[role.Id]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindRole, Name: role.Name, Change: DiffAdded, Details: lastUpdate("", role.LastUpdate)})
+			continue
+		}
+		delete(oldRoles, role.Id)
+
+		var details []string
+		details = appendField(details, "name", prev.Name, role.Name)
+		details = appendField(details, "description", prev.Description, role.Description)
+		details = appendField(details, "conditionalExpression", ExpressionString(prev.ConditionalExpression), ExpressionString(role.ConditionalExpression))
+
+		added, removed := diffNames(abilityNames(before, prev), abilityNames(after, role))
+		for _, name := range added {
+			details = append(details, "+ functional ability "+name)
+		}
+		for _, name := range removed {
+			details = append(details, "- functional ability "+name)
+		}
+
+		if len(details) > 0 || prev.LastUpdate != role.LastUpdate {
+			diffs = append(diffs, Difference{Kind: KindRole, Name: role.Name, Change: DiffChanged, Details: append(details, lastUpdate(prev.LastUpdate, role.LastUpdate)...)})
+		}
+	}
+	for _, role := range oldRoles {
+		diffs = append(diffs, Difference{Kind: KindRole, Name: role.Name, Change: DiffRemoved})
+	}
+
+	oldAbilities := make(map[string]FunctionalAbilities)
+	for _, ability := range before.FunctionalAbilities {
+		oldAbilities[ability.Id] = ability
+	}
+	for _, ability := range after.FunctionalAbilities {
+		prev, ok := oldAbilities[ability.Id]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindAbility, Name: ability.Name, Change: DiffAdded, Details: lastUpdate("", ability.LastUpdate)})
+			continue
+		}
+		delete(oldAbilities, ability.Id)
+
+		var details []string
+		details = appendField(details, "name", prev.Name, ability.Name)
+		details = appendField(details, "description", prev.Description, ability.Description)
+		details = appendField(details, "dataClassification", fmt.Sprint(prev.DataClassification), fmt.Sprint(ability.DataClassification))
+		details = appendField(details, "sodRole", prev.SodRole, ability.SodRole)
+		details = appendField(details, "entityAccess", jsonString(prev.FunctionalAbilityEntityAccess), jsonString(ability.FunctionalAbilityEntityAccess))
+
+		if len(details) > 0 || prev.LastUpdate != ability.LastUpdate {
+			diffs = append(diffs, Difference{Kind: KindAbility, Name: ability.Name, Change: DiffChanged, Details: append(details, lastUpdate(prev.LastUpdate, ability.LastUpdate)...)})
+		}
+	}
+	for _, ability := range oldAbilities {
+		diffs = append(diffs, Difference{Kind: KindAbility, Name: ability.Name, Change: DiffRemoved})
+	}
+
+	oldGroups := make(map[string]GroupState)
+	for _, group := range before.Groups {
+		oldGroups[group.Group.Id] = group
+	}
+	for _, group := range after.Groups {
+		prev, ok := oldGroups[group.Group.Id]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindGroup, Name: group.Group.Name, Change: DiffAdded, Details: lastUpdate("", group.Group.LastUpdate)})
+			prev = GroupState{Group: group.Group}
+		} else {
+			delete(oldGroups, group.Group.Id)
+
+			details := appendField(nil, "name", prev.Group.Name, group.Group.Name)
+			if len(details) > 0 || prev.Group.LastUpdate != group.Group.LastUpdate {
+				diffs = append(diffs, Difference{Kind: KindGroup, Name: group.Group.Name, Change: DiffChanged, Details: append(details, lastUpdate(prev.Group.LastUpdate, group.Group.LastUpdate)...)})
+			}
+		}
+
+		diffs = append(diffs, diffGroupContents(before, prev, after, group)...)
+	}
+	for _, group := range oldGroups {
+		diffs = append(diffs, Difference{Kind: KindGroup, Name: group.Group.Name, Change: DiffRemoved, Details: []string{fmt.Sprintf("%d member(s) at the time", len(group.Members))}})
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if diffs[i].Kind != diffs[j].Kind {
+			return diffs[i].Kind < diffs[j].Kind
+		}
+		return strings.ToLower(diffs[i].Name) < strings.ToLower(diffs[j].Name)
+	})
+	return diffs
+}
+
+func diffGroupContents(beforeState *State, before GroupState, afterState *State, after GroupState) []Difference {
+	var diffs []Difference
+	groupName := after.Group.Name
+
+	roleName := func(state *State, roleId string) string {
+		if role, ok := state.Role(roleId); ok {
+			return role.Name
+		}
+		return roleId
+	}
+
+	var beforeRoles, afterRoles []string
+	for _, binding := range before.Roles {
+		beforeRoles = append(beforeRoles, roleName(beforeState, binding.RoleId))
+	}
+	for _, binding := range after.Roles {
+		afterRoles = append(afterRoles, roleName(afterState, binding.RoleId))
+	}
+	added, removed := diffNames(beforeRoles, afterRoles)
+	for _, name := range added {
+		diffs = append(diffs, Difference{Kind: KindGroupRole, Name: groupName + " / " + name, Change: DiffAdded})
+	}
+	for _, name := range removed {
+		diffs = append(diffs, Difference{Kind: KindGroupRole, Name: groupName + " / " + name, Change: DiffRemoved})
+	}
+
+	beforeAttrs := make(map[string]GroupAttributes)
+	for _, attr := range before.Attributes {
+		beforeAttrs[attr.Id] = attr
+	}
+	for _, attr := range after.Attributes {
+		name := groupName + " / " + attr.AttributeName
+		prev, ok := beforeAttrs[attr.Id]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindAttribute, Name: name, Change: DiffAdded, Details: []string{fmt.Sprintf("value: %q", attr.AttributeValue)}})
+			continue
+		}
+		delete(beforeAttrs, attr.Id)
+		if prev.AttributeValue != attr.AttributeValue || prev.LastUpdate != attr.LastUpdate {
+			details := appendField(nil, "value", prev.AttributeValue, attr.AttributeValue)
+			diffs = append(diffs, Difference{Kind: KindAttribute, Name: name, Change: DiffChanged, Details: append(details, lastUpdate(prev.LastUpdate, attr.LastUpdate)...)})
+		}
+	}
+	for _, attr := range beforeAttrs {
+		diffs = append(diffs, Difference{Kind: KindAttribute, Name: groupName + " / " + attr.AttributeName, Change: DiffRemoved})
+	}
+
+	beforeMembers := make(map[string]User)
+	for _, user := range before.Members {
+		beforeMembers[strings.ToLower(user.IdAtSourceSystem)] = user
+	}
+	for _, user := range after.Members {
+		key := strings.ToLower(user.IdAtSourceSystem)
+		prev, ok := beforeMembers[key]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: KindMember, Name: groupName + " / " + user.IdAtSourceSystem, Change: DiffAdded, Details: userDetails(user)})
+			continue
+		}
+		delete(beforeMembers, key)
+		if prev.IsActive != user.IsActive {
+			diffs = append(diffs, Difference{Kind: KindMember, Name: groupName + " / " + user.IdAtSourceSystem, Change: DiffChanged, Details: []string{fmt.Sprintf("active: %t => %t", prev.IsActive, user.IsActive)}})
+		}
+	}
+	for _, user := range beforeMembers {
+		diffs = append(diffs, Difference{Kind: KindMember, Name: groupName + " / " + user.IdAtSourceSystem, Change: DiffRemoved, Details: userDetails(user)})
+	}
+
+	return diffs
+}
+
+func userDetails(user User) []string {
+	if user.CommonName == "" {
+		return nil
+	}
+	return []string{user.CommonName}
+}
+
+func abilityNames(state *State, role Role) []string {
+	var names []string
+	for _, ability := range state.RoleAbilities(role) {
+		names = append(names, ability.Name)
+	}
+	return names
+}
+
+func appendField(details []string, field string, before string, after string) []string {
+	if before == after {
+		return details
+	}
+	return append(details, fmt.Sprintf("%s: %q => %q", field, before, after))
+}
+
+func lastUpdate(before string, after string) []string {
+	switch {
+	case after == "" || before == after:
+		return nil
+	case before == "":
+		return []string{"last update: " + after}
+	default:
+		return []string{"last update: " + before + " => " + after}
+	}
+}
+
+func jsonString(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentsFile lists the Keystone environments besides the built-in prod one.
+//
+//	environments:
+//	  - name: stage
+//	    apiServer: https://api.stg.keystone.example.com
+//	    application: TWDC.ParksandResorts.c3po-stage
+//	    applicationId: 00000000-0000-0000-0000-000000000000
+const EnvironmentsFile = "~/.c3po/environments.yaml"
+
+// Environment is a Keystone API server and the C3PO application in it.
+type Environment struct {
+	Name          string `json:"name" yaml:"name"`
+	APIServer     string `json:"apiServer" yaml:"apiServer"`
+	Application   string `json:"application" yaml:"application"`
+	ApplicationID string `json:"applicationId" yaml:"applicationId"`
+}
+
+// DefaultEnvironment is the production C3PO application.
+func DefaultEnvironment() Environment {
+	return Environment{
+		Name:          keystoneTarget,
+		APIServer:     keystoneAPIServer,
+		Application:   keystoneApplication,
+		ApplicationID: keystoneApplicationID,
+	}
+}
+
+// LoadEnvironments returns the built-in prod environment plus the ones
+// declared in path. A missing file is not an error.
+func LoadEnvironments(path string) (map[string]Environment, error) {
+	envs := map[string]Environment{keystoneTarget: DefaultEnvironment()}
+
+	absPath, err := AbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(absPath)
+	if os.IsNotExist(err) {
+		return envs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Environments []Environment `yaml:"environments"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	for _, env := range file.Environments {
+		name := strings.ToLower(strings.TrimSpace(env.Name))
+		if name == "" {
+			return nil, fmt.Errorf("%s: environment without a name", path)
+		}
+		if env.APIServer == "" || env.ApplicationID == "" {
+			return nil, fmt.Errorf("%s: environment %q needs apiServer and applicationId", path, env.Name)
+		}
+		env.Name = name
+		envs[name] = env
+	}
+	return envs, nil
+}
+
+// LookupEnvironment returns the named environment from LoadEnvironments(path).
+func LookupEnvironment(path string, name string) (Environment, error) {
+	envs, err := LoadEnvironments(path)
+	if err != nil {
+		return Environment{}, err
+	}
+
+	env, ok := envs[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		var names []string
+		for n := range envs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Environment{}, fmt.Errorf("unknown environment %q (known: %s; add it to %s)", name, strings.Join(names, ", "), path)
+	}
+	return env, nil
+}
+
+// Environment returns the name of the environment the client talks to.
+func (c *Client) Environment() string {
+	return c.c3poEnvironment
+}
+
+// ApplicationID returns the id of the C3PO application the client manages.
+func (c *Client) ApplicationID() string {
+	return c.c3poApplicationID
+}
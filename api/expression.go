@@ -0,0 +1,677 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a parsed role ConditionalExpression. The text syntax is
+//
+//	Department == "Finance" and (Title contains "Manager" or Country in ["US", "CA"])
+//
+// with the operators == != < <= > >= contains startswith endswith matches in
+// and "not in", combined with and, or, not and parentheses. A bare attribute
+// is true when it is set, not false and not empty. Attribute names are
+// matched ignoring case; strings are compared ignoring case.
+//
+// Keystone may also return the expression as JSON, either as such a string or
+// as a tree of {"Operator": "And", "Operands": [...]} and
+// {"Attribute": "Department", "Operator": "Equals", "Value": "Finance"}
+// objects; ParseExpression accepts both.
+type Expression interface {
+	// String pretty-prints the expression in the text syntax.
+	String() string
+	eval(attrs map[string]interface{}, trace *[]string) bool
+	precedence() int
+}
+
+// ParseExpression parses a ConditionalExpression as returned by Keystone or
+// given on the command line. A nil or empty expression yields nil.
+func ParseExpression(expression interface{}) (Expression, error) {
+	switch v := expression.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		return parseExpressionText(v)
+	case map[string]interface{}:
+		return parseExpressionTree(v)
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		return parseExpressionTree(map[string]interface{}{"Operator": "And", "Operands": v})
+	}
+	return nil, fmt.Errorf("unsupported conditional expression %s", ExpressionString(expression))
+}
+
+// ValidateExpression reports whether a ConditionalExpression can be parsed.
+func ValidateExpression(expression interface{}) error {
+	_, err := ParseExpression(expression)
+	return err
+}
+
+// FormatExpression pretty-prints a ConditionalExpression, falling back to its
+// raw form when it cannot be parsed.
+func FormatExpression(expression interface{}) string {
+	parsed, err := ParseExpression(expression)
+	if err != nil || parsed == nil {
+		return ExpressionString(expression)
+	}
+	return parsed.String()
+}
+
+// EvaluateExpression evaluates an expression against attributes and returns
+// the result with a line per comparison showing the values it used. A nil
+// expression is true.
+func EvaluateExpression(expression Expression, attrs map[string]interface{}) (bool, []string) {
+	if expression == nil {
+		return true, nil
+	}
+	var trace []string
+	return expression.eval(normalizeAttributes(attrs), &trace), trace
+}
+
+// UserAttributes returns the attributes of a user an expression can test: the
+// fields of the Keystone user record, by their JSON names.
+func UserAttributes(user User) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	data, err := json.Marshal(user)
+	if err == nil {
+		json.Unmarshal(data, &attrs)
+	}
+	return attrs
+}
+
+func normalizeAttributes(attrs map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{}, len(attrs))
+	for key, value := range attrs {
+		normalized[strings.ToLower(key)] = value
+	}
+	return normalized
+}
+
+// AST
+
+type logicalExpression struct {
+	and         bool
+	left, right Expression
+}
+
+func (e logicalExpression) String() string {
+	op := " or "
+	if e.and {
+		op = " and "
+	}
+	return wrapExpression(e.left, e.precedence()) + op + wrapExpression(e.right, e.precedence())
+}
+
+func (e logicalExpression) precedence() int {
+	if e.and {
+		return 2
+	}
+	return 1
+}
+
+func (e logicalExpression) eval(attrs map[string]interface{}, trace *[]string) bool {
+	left := e.left.eval(attrs, trace)
+	if e.and && !left || !e.and && left {
+		return left
+	}
+	return e.right.eval(attrs, trace)
+}
+
+type notExpression struct {
+	operand Expression
+}
+
+func (e notExpression) String() string {
+	return "not " + wrapExpression(e.operand, e.precedence())
+}
+
+func (e notExpression) precedence() int {
+	return 3
+}
+
+func (e notExpression) eval(attrs map[string]interface{}, trace *[]string) bool {
+	return !e.operand.eval(attrs, trace)
+}
+
+type comparison struct {
+	attribute string
+	op        string
+	value     interface{}
+	pattern   *regexp.Regexp
+}
+
+func (e comparison) String() string {
+	if e.op == "" {
+		return e.attribute
+	}
+	return e.attribute + " " + e.op + " " + formatExpressionValue(e.value)
+}
+
+func (e comparison) precedence() int {
+	return 4
+}
+
+func (e comparison) eval(attrs map[string]interface{}, trace *[]string) bool {
+	actual, set := attrs[strings.ToLower(e.attribute)]
+	result := e.compare(actual, set)
+
+	shown := "unset"
+	if set {
+		shown = formatExpressionValue(actual)
+	}
+	*trace = append(*trace, fmt.Sprintf("%s: %s is %s => %t", e, e.attribute, shown, result))
+	return result
+}
+
+func (e comparison) compare(actual interface{}, set bool) bool {
+	switch e.op {
+	case "":
+		return set && truthy(actual) && actual != "" && actual != float64(0)
+	case "==":
+		return set && compareLoose(actual, e.value) == 0
+	case "!=":
+		return !set || compareLoose(actual, e.value) != 0
+	case "<", "<=", ">", ">=":
+		if !set || actual == nil {
+			return false
+		}
+		c := compareLoose(actual, e.value)
+		switch e.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	case "in", "not in":
+		found := false
+		for _, item := range e.value.([]interface{}) {
+			if set && compareLoose(actual, item) == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (e.op == "in")
+	}
+
+	if !set || actual == nil {
+		return false
+	}
+	text := strings.ToLower(scalarString(actual))
+	want := strings.ToLower(scalarString(e.value))
+	switch e.op {
+	case "contains":
+		if list, ok := actual.([]interface{}); ok {
+			for _, item := range list {
+				if compareLoose(item, e.value) == 0 {
+					return true
+				}
+			}
+			return false
+		}
+		return strings.Contains(text, want)
+	case "startswith":
+		return strings.HasPrefix(text, want)
+	case "endswith":
+		return strings.HasSuffix(text, want)
+	case "matches":
+		return e.pattern.MatchString(scalarString(actual))
+	}
+	return false
+}
+
+// compareLoose compares an attribute with a literal: numbers numerically
+// (also when the attribute is a numeric string), anything else as text,
+// ignoring case.
+func compareLoose(actual interface{}, want interface{}) int {
+	if w, ok := want.(float64); ok {
+		var a float64
+		switch v := actual.(type) {
+		case float64:
+			a = v
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return strings.Compare(strings.ToLower(v), scalarString(want))
+			}
+			a = f
+		default:
+			return compareValues(actual, want)
+		}
+		switch {
+		case a < w:
+			return -1
+		case a > w:
+			return 1
+		}
+		return 0
+	}
+	if b, ok := want.(bool); ok {
+		if s, isString := actual.(string); isString {
+			actual = strings.EqualFold(s, "true")
+		}
+		return compareValues(actual, b)
+	}
+	if want == nil || actual == nil {
+		return compareValues(actual, want)
+	}
+	return strings.Compare(strings.ToLower(scalarString(actual)), strings.ToLower(scalarString(want)))
+}
+
+func wrapExpression(e Expression, parent int) string {
+	if e.precedence() < parent {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+func formatExpressionValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatExpressionValue(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case nil:
+		return "null"
+	}
+	return scalarString(value)
+}
+
+func newComparison(attribute string, op string, value interface{}) (Expression, error) {
+	if attribute == "" {
+		return nil, fmt.Errorf("comparison without an attribute")
+	}
+	e := comparison{attribute: attribute, op: op, value: value}
+
+	switch op {
+	case "in", "not in":
+		if _, ok := value.([]interface{}); !ok {
+			return nil, fmt.Errorf("%s %s needs a list like [\"a\", \"b\"]", attribute, op)
+		}
+	case "matches":
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s matches needs a string pattern", attribute)
+		}
+		pattern, err := regexp.Compile("(?i)" + text)
+		if err != nil {
+			return nil, fmt.Errorf("%s matches: %w", attribute, err)
+		}
+		e.pattern = pattern
+	case "":
+	default:
+		if _, ok := value.([]interface{}); ok {
+			return nil, fmt.Errorf("%s %s cannot take a list", attribute, op)
+		}
+	}
+	return e, nil
+}
+
+// Text syntax
+
+type expressionParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseExpressionText(text string) (Expression, error) {
+	tokens, err := lexExpression(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid conditional expression %q: %w", text, err)
+	}
+
+	p := &expressionParser{tokens: tokens}
+	expression, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid conditional expression %q: %w", text, err)
+	}
+	return expression, nil
+}
+
+func lexExpression(text string) ([]string, error) {
+	var tokens []string
+	runes := []rune(text)
+	isWord := func(r rune) bool {
+		return r == '_' || r == '.' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		case isWord(r):
+			j := i
+			for j < len(runes) && isWord(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "<>", "&&", "||":
+					text = two
+				}
+			}
+			if !strings.Contains("()[],=!<>", string(r)) && len(text) == 1 {
+				return nil, fmt.Errorf("unexpected character %q", text)
+			}
+			tokens = append(tokens, text)
+			i += len([]rune(text))
+		}
+	}
+	return tokens, nil
+}
+
+func (p *expressionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *expressionParser) accept(words ...string) bool {
+	for _, word := range words {
+		if strings.EqualFold(p.peek(), word) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *expressionParser) parseOr() (Expression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpression{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseAnd() (Expression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpression{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) parseUnary() (Expression, error) {
+	if p.accept("not", "!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpression{operand}, nil
+	}
+
+	if p.accept("(") {
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing \")\"")
+		}
+		return expression, nil
+	}
+
+	attribute := p.peek()
+	if attribute == "" {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if !isExpressionWord(attribute) {
+		return nil, fmt.Errorf("expected an attribute name, found %q", attribute)
+	}
+	p.pos++
+
+	op := ""
+	switch {
+	case p.accept("==", "="):
+		op = "=="
+	case p.accept("!=", "<>"):
+		op = "!="
+	case p.accept("<", "<=", ">", ">="):
+		op = p.tokens[p.pos-1]
+	case p.accept("contains", "startswith", "endswith", "matches", "in"):
+		op = strings.ToLower(p.tokens[p.pos-1])
+	case strings.EqualFold(p.peek(), "not") && p.pos+1 < len(p.tokens) && strings.EqualFold(p.tokens[p.pos+1], "in"):
+		p.pos += 2
+		op = "not in"
+	default:
+		return newComparison(attribute, "", nil)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return newComparison(attribute, op, value)
+}
+
+func (p *expressionParser) parseValue() (interface{}, error) {
+	token := p.peek()
+	if token == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+	p.pos++
+
+	switch {
+	case token == "[":
+		list := []interface{}{}
+		if p.accept("]") {
+			return list, nil
+		}
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := value.([]interface{}); ok {
+				return nil, fmt.Errorf("nested lists are not allowed")
+			}
+			list = append(list, value)
+			if p.accept("]") {
+				return list, nil
+			}
+			if !p.accept(",") {
+				return nil, fmt.Errorf("expected \",\" or \"]\", found %q", p.peek())
+			}
+		}
+	case token[0] == '"' || token[0] == '\'':
+		body := token[1 : len(token)-1]
+		if token[0] == '\'' {
+			body = strings.ReplaceAll(strings.ReplaceAll(body, "\\'", "'"), "\"", "\\\"")
+		}
+		value, err := strconv.Unquote("\"" + body + "\"")
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", token)
+		}
+		return value, nil
+	case strings.EqualFold(token, "true"):
+		return true, nil
+	case strings.EqualFold(token, "false"):
+		return false, nil
+	case strings.EqualFold(token, "null"):
+		return nil, nil
+	}
+
+	if number, err := strconv.ParseFloat(token, 64); err == nil {
+		return number, nil
+	}
+	if isExpressionWord(token) {
+		// Unquoted words are taken as strings: Department == Finance.
+		return token, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", token)
+}
+
+func isExpressionWord(token string) bool {
+	if token == "" || strings.ContainsAny(token[:1], "\"'()[],=!<>&|") {
+		return false
+	}
+	switch strings.ToLower(token) {
+	case "and", "or", "not", "in", "contains", "startswith", "endswith", "matches":
+		return false
+	}
+	return true
+}
+
+// JSON tree syntax
+
+// treeOperators maps the operator names found in JSON expression trees.
+var treeOperators = map[string]string{
+	"and": "and", "all": "and", "or": "or", "any": "or", "not": "not",
+	"equals": "==", "eq": "==", "==": "==", "=": "==",
+	"notequals": "!=", "ne": "!=", "!=": "!=",
+	"lessthan": "<", "lt": "<", "<": "<",
+	"lessthanorequals": "<=", "le": "<=", "<=": "<=",
+	"greaterthan": ">", "gt": ">", ">": ">",
+	"greaterthanorequals": ">=", "ge": ">=", ">=": ">=",
+	"contains": "contains", "startswith": "startswith", "endswith": "endswith",
+	"matches": "matches", "regex": "matches", "in": "in", "notin": "not in",
+	"exists": "",
+}
+
+func parseExpressionTree(node map[string]interface{}) (Expression, error) {
+	field := func(names ...string) (interface{}, bool) {
+		for key, value := range node {
+			for _, name := range names {
+				if strings.EqualFold(key, name) {
+					return value, true
+				}
+			}
+		}
+		return nil, false
+	}
+
+	if text, ok := field("Expression"); ok && len(node) == 1 {
+		return ParseExpression(text)
+	}
+
+	rawOp, _ := field("Operator", "Op")
+	opName, _ := rawOp.(string)
+	key := strings.ToLower(strings.NewReplacer(" ", "", "_", "").Replace(opName))
+	op, known := treeOperators[key]
+	if !known {
+		return nil, fmt.Errorf("invalid conditional expression: unknown operator %q in %s", opName, jsonString(node))
+	}
+
+	switch op {
+	case "and", "or", "not":
+		rawOperands, _ := field("Operands", "Conditions", "Expressions", "Rules")
+		operands, ok := rawOperands.([]interface{})
+		if !ok || len(operands) == 0 || op == "not" && len(operands) != 1 {
+			return nil, fmt.Errorf("invalid conditional expression: %s needs operands in %s", opName, jsonString(node))
+		}
+
+		var parsed []Expression
+		for _, operand := range operands {
+			child, ok := operand.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid conditional expression: operand %s is not an object", jsonString(operand))
+			}
+			expression, err := parseExpressionTree(child)
+			if err != nil {
+				return nil, err
+			}
+			parsed = append(parsed, expression)
+		}
+
+		if op == "not" {
+			return notExpression{parsed[0]}, nil
+		}
+		expression := parsed[0]
+		for _, next := range parsed[1:] {
+			expression = logicalExpression{and: op == "and", left: expression, right: next}
+		}
+		return expression, nil
+	}
+
+	rawAttribute, _ := field("Attribute", "AttributeName", "Field", "Name")
+	attribute, _ := rawAttribute.(string)
+	value, hasValue := field("Value", "Values")
+	if !hasValue && op != "" {
+		return nil, fmt.Errorf("invalid conditional expression: %s needs a value in %s", opName, jsonString(node))
+	}
+	expression, err := newComparison(strings.TrimSpace(attribute), op, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid conditional expression: %w", err)
+	}
+	return expression, nil
+}
+
+// ExpressionAttributes returns the attribute names an expression tests, sorted.
+func ExpressionAttributes(expression Expression) []string {
+	seen := make(map[string]string)
+	var walk func(Expression)
+	walk = func(e Expression) {
+		switch v := e.(type) {
+		case logicalExpression:
+			walk(v.left)
+			walk(v.right)
+		case notExpression:
+			walk(v.operand)
+		case comparison:
+			seen[strings.ToLower(v.attribute)] = v.attribute
+		}
+	}
+	if expression != nil {
+		walk(expression)
+	}
+
+	names := make([]string, 0, len(seen))
+	for _, name := range seen {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	return names
+}
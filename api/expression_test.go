@@ -0,0 +1,266 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseExpressionString(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // String() of the parsed expression
+	}{
+		{`Department == "Finance"`, `Department == "Finance"`},
+		{`Department = Finance`, `Department == "Finance"`},
+		{`Department <> 'Sales'`, `Department != "Sales"`},
+		{`Level >= 3`, `Level >= 3`},
+		{`Title CONTAINS "Manager"`, `Title contains "Manager"`},
+		{`Country in ["US", "CA"]`, `Country in ["US", "CA"]`},
+		{`Country not in ["US"]`, `Country not in ["US"]`},
+		{`Email endswith "@example.com"`, `Email endswith "@example.com"`},
+		{`Name matches "^j.*n$"`, `Name matches "^j.*n$"`},
+		{`IsActive`, `IsActive`},
+		{`a == 1 && b == 2 || c == 3`, `a == 1 and b == 2 or c == 3`},
+		{`a == 1 and (b == 2 or c == 3)`, `a == 1 and (b == 2 or c == 3)`},
+		{`not (a == 1 or b == 2)`, `not (a == 1 or b == 2)`},
+		{`!IsActive`, `not IsActive`},
+		{`Manager == null`, `Manager == null`},
+		{`Contractor == false`, `Contractor == false`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expression, err := ParseExpression(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpression(%q): %v", tt.input, err)
+			}
+			if got := expression.String(); got != tt.want {
+				t.Fatalf("ParseExpression(%q).String() = %q, want %q", tt.input, got, tt.want)
+			}
+
+			// The printed form parses back to the same expression.
+			again, err := ParseExpression(expression.String())
+			if err != nil {
+				t.Fatalf("ParseExpression(%q): %v", expression.String(), err)
+			}
+			if again.String() != tt.want {
+				t.Errorf("round trip of %q = %q, want %q", tt.input, again.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpressionEmpty(t *testing.T) {
+	for _, input := range []interface{}{nil, "", "   ", []interface{}{}} {
+		expression, err := ParseExpression(input)
+		if err != nil || expression != nil {
+			t.Errorf("ParseExpression(%#v) = %v, %v, want nil, nil", input, expression, err)
+		}
+	}
+}
+
+func TestParseExpressionTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string // JSON
+		want  string
+	}{
+		{"comparison", `{"Attribute": "Department", "Operator": "Equals", "Value": "Finance"}`, `Department == "Finance"`},
+		{"and", `{"Operator": "And", "Operands": [
+			{"Attribute": "Department", "Operator": "eq", "Value": "Finance"},
+			{"Attribute": "Level", "Operator": "GreaterThanOrEquals", "Value": 3}]}`, `Department == "Finance" and Level >= 3`},
+		{"or inside and", `{"Operator": "all", "Operands": [
+			{"Attribute": "a", "Operator": "==", "Value": 1},
+			{"Operator": "Any", "Conditions": [
+				{"Attribute": "b", "Operator": "ne", "Value": 2},
+				{"Attribute": "c", "Operator": "Not In", "Values": ["x", "y"]}]}]}`, `a == 1 and (b != 2 or c not in ["x", "y"])`},
+		{"not", `{"Operator": "Not", "Operands": [{"Field": "Contractor", "Operator": "Exists"}]}`, `not Contractor`},
+		{"text inside JSON", `{"Expression": "Title startswith \"VP\""}`, `Title startswith "VP"`},
+		{"text as JSON string", `"Country in [\"US\"]"`, `Country in ["US"]`},
+		{"list is and", `[
+			{"Attribute": "a", "Operator": "lt", "Value": 1},
+			{"Attribute": "b", "Operator": "regex", "Value": "^x"}]`, `a < 1 and b matches "^x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input interface{}
+			if err := json.Unmarshal([]byte(tt.input), &input); err != nil {
+				t.Fatal(err)
+			}
+			expression, err := ParseExpression(input)
+			if err != nil {
+				t.Fatalf("ParseExpression: %v", err)
+			}
+			if got := expression.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateExpression(t *testing.T) {
+	attrs := map[string]interface{}{
+		"Department": "Finance",
+		"Title":      "Senior Manager",
+		"Level":      float64(4),
+		"Grade":      "7",
+		"Country":    "us",
+		"IsActive":   true,
+		"Contractor": false,
+		"Groups":     []interface{}{"Audit", "Payroll"},
+		"Manager":    nil,
+		"Nickname":   "",
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{`Department == "Finance"`, true},
+		{`department == "finance"`, true},
+		{`Department != "Finance"`, false},
+		{`Level > 3`, true},
+		{`Level <= 3`, false},
+		{`Grade >= 7`, true},
+		{`Grade < 10`, true},
+		{`Title contains "manager"`, true},
+		{`Title startswith "senior"`, true},
+		{`Title endswith "Director"`, false},
+		{`Title matches "^SENIOR\\s"`, true},
+		{`Country in ["US", "CA"]`, true},
+		{`Country not in ["US", "CA"]`, false},
+		{`Groups contains "payroll"`, true},
+		{`Groups contains "Pay"`, false},
+		{`IsActive`, true},
+		{`Contractor`, false},
+		{`Nickname`, false},
+		{`Contractor == false`, true},
+		{`not Contractor and IsActive`, true},
+		{`Level > 10 or Department == "Finance"`, true},
+		{`Level > 10 or (Department == "Finance" and Contractor)`, false},
+
+		// Unset attributes.
+		{`Office == "Paris"`, false},
+		{`Office != "Paris"`, true},
+		{`Office in ["Paris"]`, false},
+		{`Office not in ["Paris"]`, true},
+		{`Office > 1`, false},
+		{`Office contains "a"`, false},
+		{`Office`, false},
+		{`not Office`, true},
+		{`Manager == null`, true},
+		{`Manager > 1`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			expression, err := ParseExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseExpression: %v", err)
+			}
+			got, trace := EvaluateExpression(expression, attrs)
+			if got != tt.want {
+				t.Errorf("EvaluateExpression(%q) = %t, want %t\n%s", tt.expression, got, tt.want, strings.Join(trace, "\n"))
+			}
+		})
+	}
+
+	if got, trace := EvaluateExpression(nil, attrs); !got || trace != nil {
+		t.Errorf("EvaluateExpression(nil) = %t, %v, want true, nil", got, trace)
+	}
+}
+
+func TestEvaluateExpressionTrace(t *testing.T) {
+	expression, err := ParseExpression(`Office == "Paris" or Level > 3`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, trace := EvaluateExpression(expression, map[string]interface{}{"level": float64(4)})
+	want := []string{
+		`Office == "Paris": Office is unset => false`,
+		`Level > 3: Level is 4 => true`,
+	}
+	if strings.Join(trace, "\n") != strings.Join(want, "\n") {
+		t.Errorf("trace = %q, want %q", trace, want)
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		want  string // part of the error message
+	}{
+		{"unterminated string", `Department == "Finance`, "unterminated string"},
+		{"unexpected character", `Level + 1`, "unexpected character"},
+		{"missing value", `Department ==`, "missing value"},
+		{"missing parenthesis", `(a == 1 or b == 2`, `missing ")"`},
+		{"trailing token", `a == 1 b`, `unexpected "b"`},
+		{"dangling and", `a == 1 and`, "unexpected end of expression"},
+		{"operator as attribute", `== 1`, "expected an attribute name"},
+		{"in without list", `Country in "US"`, "needs a list"},
+		{"list for equals", `Country == ["US"]`, "cannot take a list"},
+		{"nested list", `Country in [["US"]]`, "nested lists"},
+		{"bad list", `Country in ["US" "CA"]`, `expected "," or "]"`},
+		{"bad regex", `Name matches "("`, "matches"},
+		{"matches number", `Name matches 1`, "needs a string pattern"},
+		{"unknown tree operator", map[string]interface{}{"Operator": "Between", "Attribute": "a", "Value": 1.0}, `unknown operator "Between"`},
+		{"tree without operands", map[string]interface{}{"Operator": "And"}, "needs operands"},
+		{"tree without value", map[string]interface{}{"Operator": "Equals", "Attribute": "a"}, "needs a value"},
+		{"tree without attribute", map[string]interface{}{"Operator": "Equals", "Value": 1.0}, "without an attribute"},
+		{"tree operand not object", map[string]interface{}{"Operator": "Or", "Operands": []interface{}{"a"}}, "is not an object"},
+		{"unsupported type", 42.0, "unsupported conditional expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpression(tt.input)
+			if err == nil {
+				t.Fatalf("ParseExpression(%v): expected an error", tt.input)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseExpression(%v): error %q does not contain %q", tt.input, err, tt.want)
+			}
+			if ValidateExpression(tt.input) == nil {
+				t.Errorf("ValidateExpression(%v) accepted an invalid expression", tt.input)
+			}
+		})
+	}
+}
+
+func TestExpressionAttributes(t *testing.T) {
+	expression, err := ParseExpression(`title contains "x" and (Department == "a" or not department) and Level > 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Attributes are listed once, ignoring case, in either spelling.
+	got := strings.Join(ExpressionAttributes(expression), ",")
+	if strings.ToLower(got) != "department,level,title" {
+		t.Errorf("ExpressionAttributes = %s, want Department,Level,title", got)
+	}
+}
+
+func TestExpressionValue(t *testing.T) {
+	var tree interface{}
+	if err := json.Unmarshal([]byte(`{"Attribute": "Department", "Operator": "Equals", "Value": "Finance"}`), &tree); err != nil {
+		t.Fatal(err)
+	}
+
+	// A tree exported to a manifest is sent back as a tree.
+	value := expressionValue(ExpressionString(tree))
+	if _, ok := value.(map[string]interface{}); !ok {
+		t.Fatalf("expressionValue(ExpressionString(tree)) = %#v, want a JSON object", value)
+	}
+	if ExpressionString(value) != ExpressionString(tree) {
+		t.Errorf("round trip = %s, want %s", ExpressionString(value), ExpressionString(tree))
+	}
+
+	if value := expressionValue(`Department == "Finance"`); value != `Department == "Finance"` {
+		t.Errorf("expressionValue(text) = %#v, want the text", value)
+	}
+	if value := expressionValue("  "); value != nil {
+		t.Errorf("expressionValue(blank) = %#v, want nil", value)
+	}
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// GrantLedgerFile records time-bound grants unless another file is given. To
+// share grants between operators (and the host running 'grant reap'), point
+// --ledger at a file on a shared filesystem.
+const GrantLedgerFile = "~/.c3po/grants.json"
+
+// grantLockTimeout is how long to wait for another c3po updating the ledger.
+const grantLockTimeout = 30 * time.Second
+
+// Grant statuses, as reported by Grant.Status.
+const (
+	GrantActive  = "active"
+	GrantExpired = "expired"
+	GrantRevoked = "revoked"
+)
+
+// Grant is a group membership that must be revoked at Expires.
+type Grant struct {
+	Id            string     `json:"Id"`
+	ApplicationId string     `json:"ApplicationId"`
+	Environment   string     `json:"Environment"`
+	HubID         string     `json:"HubID"`
+	UserId        string     `json:"UserId"`
+	Group         string     `json:"Group"`
+	GroupId       string     `json:"GroupId"`
+	Reason        string     `json:"Reason,omitempty"`
+	GrantedBy     string     `json:"GrantedBy"`
+	Granted       time.Time  `json:"Granted"`
+	Expires       time.Time  `json:"Expires"`
+	Revoked       *time.Time `json:"Revoked,omitempty"`
+	RevokedBy     string     `json:"RevokedBy,omitempty"`
+}
+
+// Status returns whether the grant is active, expired (but not yet revoked)
+// or revoked at now.
+func (g Grant) Status(now time.Time) string {
+	switch {
+	case g.Revoked != nil:
+		return GrantRevoked
+	case !now.Before(g.Expires):
+		return GrantExpired
+	}
+	return GrantActive
+}
+
+// ParseGrantDuration parses a grant length: a Go duration ("36h") or a whole
+// number of days ("14d") or weeks ("2w").
+func ParseGrantDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); strings.HasSuffix(value, suffix) && err == nil {
+			if n <= 0 {
+				return 0, fmt.Errorf("grant duration %q must be positive", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid grant duration %q (expected e.g. 14d, 2w or 36h)", value)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("grant duration %q must be positive", value)
+	}
+	return d, nil
+}
+
+// ReadGrantLedger returns every grant in the ledger, sorted by expiry. A
+// missing ledger holds no grants.
+func ReadGrantLedger(path string) ([]Grant, error) {
+	absPath, err := AbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return readGrants(absPath)
+}
+
+// UpdateGrantLedger locks the ledger, passes its grants to update and writes
+// back what update returns. The file is replaced atomically, so readers never
+// see a partial ledger.
+func UpdateGrantLedger(path string, update func([]Grant) ([]Grant, error)) error {
+	absPath, err := AbsolutePath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(absPath), 0700); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(absPath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	grants, err := readGrants(absPath)
+	if err != nil {
+		return err
+	}
+	if grants, err = update(grants); err != nil {
+		return err
+	}
+	sortGrants(grants)
+
+	data, err := json.MarshalIndent(grants, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := absPath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, absPath)
+}
+
+func readGrants(absPath string) ([]Grant, error) {
+	data, err := os.ReadFile(absPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []Grant
+	if err := json.Unmarshal(data, &grants); err != nil {
+		return nil, fmt.Errorf("reading grant ledger %s: %w", absPath, err)
+	}
+	sortGrants(grants)
+	return grants, nil
+}
+
+func sortGrants(grants []Grant) {
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].Expires.Before(grants[j].Expires)
+	})
+}
+
+// lockFile creates path exclusively, waiting while another process holds it,
+// and returns the function that releases it. The lock holds the PID and host
+// of its owner and a token that tells it apart from every other lock; it is
+// taken over when that process is gone or when it is older than
+// grantLockTimeout, since the ledger is never locked for longer.
+func lockFile(path string) (func(), error) {
+	host, _ := os.Hostname()
+	token := make([]byte, 8)
+	rand.Read(token)
+	owner := fmt.Sprintf("%d %s %s\n", os.Getpid(), host, hex.EncodeToString(token))
+
+	deadline := time.Now().Add(grantLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() { releaseLock(path, owner) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if data, stale := staleLock(path, host); stale {
+			takeOverLock(path, data)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by another c3po; remove it if no c3po is running", path)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// releaseLock removes the lock at path if it is still the one owner took, and
+// not one that replaced it after a takeover.
+func releaseLock(path string, owner string) {
+	if data, err := os.ReadFile(path); err == nil && string(data) == owner {
+		os.Remove(path)
+	}
+}
+
+// takeOverLock removes the stale lock at path, whose contents were data. Several
+// waiters may find the same lock stale, so it is moved to a name of our own
+// first and compared again: when another waiter already took it over and the
+// lock moved is its fresh one, that lock is put back.
+func takeOverLock(path string, data []byte) {
+	moved := fmt.Sprintf("%s.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, moved); err != nil {
+		return
+	}
+	if current, err := os.ReadFile(moved); err == nil && !bytes.Equal(current, data) {
+		// Link fails rather than replace a lock created in the meantime.
+		os.Link(moved, path)
+	}
+	os.Remove(moved)
+}
+
+// staleLock reads the lock at path and reports whether it was left behind: it
+// is older than grantLockTimeout, or its owner ran on this host and is no
+// longer running.
+func staleLock(path string, host string) ([]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(info.ModTime()) > grantLockTimeout {
+		return data, true
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 || fields[1] != host {
+		// Held from another host (shared ledger), or still being written.
+		return nil, false
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, false
+	}
+	return data, !processRunning(pid)
+}
+
+// processRunning reports whether a process with the PID exists on this host.
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess fails on Windows when there is no such process.
+		process.Release()
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// ActiveGrant returns the unrevoked grant of the group to the user, if any.
+func ActiveGrant(grants []Grant, groupId string, userId string) (int, bool) {
+	for i, grant := range grants {
+		if grant.Revoked == nil && grant.GroupId == groupId && grant.UserId == userId {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// NewGrant describes a grant of group to user for d, starting now.
+func (c *Client) NewGrant(user User, group Group, d time.Duration, reason string) Grant {
+	id := make([]byte, 4)
+	rand.Read(id)
+	now := time.Now().UTC()
+	return Grant{
+		Id:            hex.EncodeToString(id),
+		ApplicationId: c.c3poApplicationID,
+		Environment:   c.c3poEnvironment,
+		HubID:         user.IdAtSourceSystem,
+		UserId:        user.Id,
+		Group:         group.Name,
+		GroupId:       group.Id,
+		Reason:        reason,
+		GrantedBy:     c.c3poUsername,
+		Granted:       now,
+		Expires:       now.Add(d),
+	}
+}
+
+// RevokeGrant removes the grant's membership and marks it revoked. A user who
+// already left the group counts as revoked.
+func (c *Client) RevokeGrant(grant *Grant) error {
+	members, err := c.memberIndex(grant.GroupId)
+	if err != nil {
+		return err
+	}
+	if members[grant.UserId] {
+		if err := c.RemoveGroupMember(grant.GroupId, grant.UserId); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	grant.Revoked = &now
+	grant.RevokedBy = c.c3poUsername
+	return nil
+}
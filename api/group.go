@@ -0,0 +1,127 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LookupGroup returns the single C3PO group named name. The "C3PO - " prefix
+// is optional and the comparison ignores case and repeated spaces.
+func (c *Client) LookupGroup(name string) (Group, error) {
+	matches, err := c.FindGroups(name, MatchExact, 0)
+	if err != nil {
+		return Group{}, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return Group{}, fmt.Errorf("group %q not found", name)
+	case 1:
+		return matches[0].Group, nil
+	}
+
+	var names []string
+	for _, m := range matches {
+		names = append(names, m.Group.Name)
+	}
+	return Group{}, fmt.Errorf("group %q is ambiguous: %s", name, strings.Join(names, ", "))
+}
+
+// groupKey reduces a group name to what identifies it: no "C3PO - " prefix,
+// single spaces, lower case.
+func groupKey(name string) string {
+	return normalizeName(name)
+}
+
+// groupPrefix is the prefix every C3PO group name carries.
+const groupPrefix = "C3PO - "
+
+// GroupName applies the C3PO naming convention to a role or studio name:
+// "C3PO - <Name>" with single spaces. The prefix may already be present, in any
+// of the spellings removeC3POPrefixes understands.
+func (c *Client) GroupName(name string) (string, error) {
+	base := strings.TrimSpace(c.reduceSpaces(c.removeC3POPrefixes(strings.TrimSpace(name))))
+	if base == "" {
+		return "", fmt.Errorf("group name %q is empty once the 'C3PO - ' prefix is removed", name)
+	}
+	if strings.HasSuffix(base, "-") {
+		return "", fmt.Errorf("group name %q must not end with a dash", name)
+	}
+	return groupPrefix + base, nil
+}
+
+// ValidateGroupName reports whether name already follows the C3PO naming convention.
+func (c *Client) ValidateGroupName(name string) error {
+	canonical, err := c.GroupName(name)
+	if err != nil {
+		return err
+	}
+	if canonical != name {
+		return fmt.Errorf("group name %q does not follow the convention, expected %q", name, canonical)
+	}
+	return nil
+}
+
+// checkDuplicateGroup fails if another group (other than exceptId) already
+// uses name, ignoring the "C3PO - " prefix, case and repeated spaces.
+func (c *Client) checkDuplicateGroup(name string, exceptId string) error {
+	groups, err := c.ListGroups()
+	if err != nil {
+		return err
+	}
+	key := groupKey(name)
+	for _, group := range groups {
+		if group.Id != exceptId && groupKey(group.Name) == key {
+			return fmt.Errorf("group %q already exists as %q", name, group.Name)
+		}
+	}
+	return nil
+}
+
+// CreateGroup creates a C3PO group, applying the naming convention to name.
+func (c *Client) CreateGroup(name string) (Group, error) {
+	groupName, err := c.GroupName(name)
+	if err != nil {
+		return Group{}, err
+	}
+	if err := c.checkDuplicateGroup(groupName, ""); err != nil {
+		return Group{}, err
+	}
+	return c.createGroup(groupName)
+}
+
+// createGroup creates a group named exactly name, without applying the naming
+// convention (restore brings back legacy names as Keystone held them).
+func (c *Client) createGroup(name string) (Group, error) {
+	group := Group{Name: name}
+	created := group
+	if err := c.keystone("POST", "adminservice/keystone/v1/group", "", group, &created); err != nil {
+		return Group{}, err
+	}
+	return created, nil
+}
+
+// RenameGroup renames a C3PO group, applying the naming convention to newName.
+func (c *Client) RenameGroup(group Group, newName string) (Group, error) {
+	groupName, err := c.GroupName(newName)
+	if err != nil {
+		return Group{}, err
+	}
+	if err := c.checkDuplicateGroup(groupName, group.Id); err != nil {
+		return Group{}, err
+	}
+
+	group.Name = groupName
+	if err := c.keystone("PUT", "adminservice/keystone/v1/group/"+group.Id, "", group, nil); err != nil {
+		return Group{}, err
+	}
+	return group, nil
+}
+
+// DeleteGroup removes a group.
+func (c *Client) DeleteGroup(groupId string) error {
+	if groupId == "" {
+		return fmt.Errorf("group id cannot be empty")
+	}
+	return c.keystone("DELETE", "adminservice/keystone/v1/group/"+groupId, "", nil, nil)
+}
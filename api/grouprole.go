@@ -0,0 +1,75 @@
+package api
+
+import "fmt"
+
+// ListGroupRoles returns the role bindings of a group. The binding Id is the
+// GroupRoleId used by group-role attributes.
+func (c *Client) ListGroupRoles(groupId string) ([]GroupRole, error) {
+	var bindings []GroupRole
+	err := c.keystone("GET", "adminservice/keystone/v1/group/"+groupId+"/role", "", nil, &bindings)
+	if err != nil {
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// FindGroupRole returns the binding of roleId among bindings.
+func FindGroupRole(bindings []GroupRole, roleId string) (GroupRole, bool) {
+	for _, binding := range bindings {
+		if binding.RoleId == roleId {
+			return binding, true
+		}
+	}
+	return GroupRole{}, false
+}
+
+// AssignRole binds a role to a group and returns the new binding. Assigning a
+// role that is already bound returns the existing binding.
+func (c *Client) AssignRole(groupId string, roleId string) (GroupRole, error) {
+	bindings, err := c.ListGroupRoles(groupId)
+	if err != nil {
+		return GroupRole{}, err
+	}
+	if binding, ok := FindGroupRole(bindings, roleId); ok {
+		return binding, nil
+	}
+
+	return c.AddGroupRole(groupId, roleId)
+}
+
+// AddGroupRole creates a role binding without checking for an existing one.
+func (c *Client) AddGroupRole(groupId string, roleId string) (GroupRole, error) {
+	binding := GroupRole{GroupId: groupId, RoleId: roleId}
+	created := binding
+	if err := c.keystone("POST", "adminservice/keystone/v1/group/"+groupId+"/role", "", binding, &created); err != nil {
+		return GroupRole{}, err
+	}
+	return created, nil
+}
+
+// UnassignRole removes a role binding from a group.
+func (c *Client) UnassignRole(groupId string, groupRoleId string) error {
+	if groupRoleId == "" {
+		return fmt.Errorf("group role id cannot be empty")
+	}
+	return c.keystone("DELETE", "adminservice/keystone/v1/group/"+groupId+"/role/"+groupRoleId, "", nil, nil)
+}
+
+// GroupRoleBinding looks up the binding of the named role on a group.
+func (c *Client) GroupRoleBinding(groupId string, rolename string) (GroupRole, error) {
+	role, err := c.LookupRole(rolename)
+	if err != nil {
+		return GroupRole{}, err
+	}
+
+	bindings, err := c.ListGroupRoles(groupId)
+	if err != nil {
+		return GroupRole{}, err
+	}
+
+	binding, ok := FindGroupRole(bindings, role.Id)
+	if !ok {
+		return GroupRole{}, fmt.Errorf("role %q is not assigned to the group", role.Name)
+	}
+	return binding, nil
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint severities, from the most to the least serious.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+	LintInfo    = "info"
+)
+
+var lintSeverityRank = map[string]int{LintError: 0, LintWarning: 1, LintInfo: 2}
+
+// LintCheck is one hygiene rule applied by Lint.
+type LintCheck struct {
+	Id          string
+	Severity    string
+	Description string
+}
+
+// LintChecks lists every check Lint runs.
+var LintChecks = []LintCheck{
+	{"group-name", LintError, "group name does not follow the 'C3PO - <Name>' convention"},
+	{"role-name", LintError, "role name has a 'C3PO - ' prefix or stray spaces"},
+	{"group-no-roles", LintWarning, "group is bound to no role"},
+	{"group-no-members", LintWarning, "group has no members"},
+	{"group-inactive-members", LintWarning, "every member of the group is inactive"},
+	{"role-unbound", LintWarning, "role is bound to no group"},
+	{"role-no-abilities", LintWarning, "role has no functional abilities"},
+	{"ability-unused", LintWarning, "functional ability is carried by no role"},
+	{"role-no-description", LintInfo, "role has no description"},
+	{"ability-no-description", LintInfo, "functional ability has no description"},
+}
+
+// LintFinding is one hygiene problem found by Lint.
+type LintFinding struct {
+	Check    string `json:"Check"`
+	Severity string `json:"Severity"`
+	Kind     string `json:"Kind"`
+	Name     string `json:"Name"`
+	Id       string `json:"Id"`
+	Message  string `json:"Message"`
+}
+
+// ParseLintSeverity accepts a severity name.
+func ParseLintSeverity(severity string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(severity))
+	if _, ok := lintSeverityRank[value]; !ok {
+		return "", fmt.Errorf("invalid severity %q (expected error, warning or info)", severity)
+	}
+	return value, nil
+}
+
+// LintAtLeast reports whether severity is as serious as threshold or more.
+func LintAtLeast(severity string, threshold string) bool {
+	return lintSeverityRank[severity] <= lintSeverityRank[threshold]
+}
+
+// Lint checks the roles, groups and functional abilities of the state for
+// hygiene problems (see LintChecks). Findings are sorted by severity, check
+// and name.
+func (c *Client) Lint(state *State) []LintFinding {
+	var findings []LintFinding
+	add := func(check string, kind string, name string, id string, message string) {
+		for _, lc := range LintChecks {
+			if lc.Id == check {
+				findings = append(findings, LintFinding{Check: check, Severity: lc.Severity, Kind: kind, Name: name, Id: id, Message: message})
+				return
+			}
+		}
+	}
+
+	boundRoles := make(map[string]bool)
+	for _, group := range state.Groups {
+		name, id := group.Group.Name, group.Group.Id
+
+		if err := c.ValidateGroupName(name); err != nil {
+			add("group-name", KindGroup, name, id, err.Error())
+		}
+
+		for _, binding := range group.Roles {
+			boundRoles[binding.RoleId] = true
+		}
+		if len(group.Roles) == 0 {
+			add("group-no-roles", KindGroup, name, id, "bound to no role")
+		}
+
+		active := 0
+		for _, user := range group.Members {
+			if user.IsActive {
+				active++
+			}
+		}
+		switch {
+		case len(group.Members) == 0:
+			add("group-no-members", KindGroup, name, id, "no members")
+		case active == 0:
+			add("group-inactive-members", KindGroup, name, id, fmt.Sprintf("all %d member(s) are inactive", len(group.Members)))
+		}
+	}
+
+	usedAbilities := make(map[string]bool)
+	for _, role := range state.Roles {
+		if err := c.ValidateRoleName(role.Name); err != nil {
+			add("role-name", KindRole, role.Name, role.Id, err.Error())
+		}
+		if !boundRoles[role.Id] {
+			add("role-unbound", KindRole, role.Name, role.Id, "bound to no group")
+		}
+
+		ids := RoleAbilityIds(role)
+		for _, id := range ids {
+			usedAbilities[id] = true
+		}
+		if len(ids) == 0 {
+			add("role-no-abilities", KindRole, role.Name, role.Id, "no functional abilities")
+		}
+		if strings.TrimSpace(role.Description) == "" {
+			add("role-no-description", KindRole, role.Name, role.Id, "empty description")
+		}
+	}
+
+	for _, ability := range state.FunctionalAbilities {
+		if !usedAbilities[ability.Id] {
+			add("ability-unused", KindAbility, ability.Name, ability.Id, "carried by no role")
+		}
+		if strings.TrimSpace(ability.Description) == "" {
+			add("ability-no-description", KindAbility, ability.Name, ability.Id, "empty description")
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return lintSeverityRank[a.Severity] < lintSeverityRank[b.Severity]
+		}
+		if a.Check != b.Check {
+			return a.Check < b.Check
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	return findings
+}
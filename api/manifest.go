@@ -0,0 +1,493 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest describes the desired roles and groups of the application by name,
+// so that it can be kept in git and reviewed. A nil list (the key is absent)
+// leaves that part unmanaged; an empty list means "none". This holds for the
+// roles and groups sections too, which --prune only applies to when present.
+// Functional abilities cannot be created through the API: they are exported
+// for reference and ignored by plan and apply.
+type Manifest struct {
+	Application         string            `json:"application,omitempty" yaml:"application,omitempty"`
+	Roles               []ManifestRole    `json:"roles,omitempty" yaml:"roles,omitempty"`
+	Groups              []ManifestGroup   `json:"groups,omitempty" yaml:"groups,omitempty"`
+	FunctionalAbilities []ManifestAbility `json:"functionalAbilities,omitempty" yaml:"functionalAbilities,omitempty"`
+}
+
+// ManifestRole is a role and the names of its functional abilities. An
+// expression Keystone holds as a JSON tree is kept as JSON text, and sent back
+// as a tree.
+type ManifestRole struct {
+	Name                  string   `json:"name" yaml:"name"`
+	Description           string   `json:"description,omitempty" yaml:"description,omitempty"`
+	ConditionalExpression string   `json:"conditionalExpression,omitempty" yaml:"conditionalExpression,omitempty"`
+	FunctionalAbilities   []string `json:"functionalAbilities" yaml:"functionalAbilities"`
+}
+
+// ManifestGroup is a group with its role names, attributes and member HubIDs.
+type ManifestGroup struct {
+	Name       string              `json:"name" yaml:"name"`
+	Roles      []string            `json:"roles" yaml:"roles"`
+	Attributes []ManifestAttribute `json:"attributes" yaml:"attributes"`
+	Members    []string            `json:"members" yaml:"members"`
+}
+
+// ManifestAttribute is a group attribute, or a group-role attribute when Role is set.
+type ManifestAttribute struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+	Role  string `json:"role,omitempty" yaml:"role,omitempty"`
+}
+
+// ManifestAbility is a read-only copy of a functional ability.
+type ManifestAbility struct {
+	Name               string      `json:"name" yaml:"name"`
+	Description        string      `json:"description,omitempty" yaml:"description,omitempty"`
+	DataClassification int         `json:"dataClassification" yaml:"dataClassification"`
+	SodRole            string      `json:"sodRole,omitempty" yaml:"sodRole,omitempty"`
+	EntityAccess       interface{} `json:"entityAccess,omitempty" yaml:"entityAccess,omitempty"`
+}
+
+// Sub-directories of a manifest tree written by WriteManifestTree.
+const (
+	manifestApplicationFile = "application"
+	manifestRolesDir        = "roles"
+	manifestGroupsDir       = "groups"
+	manifestAbilitiesDir    = "abilities"
+)
+
+// LoadManifest reads a YAML or JSON manifest, either a single file or a
+// directory tree written by WriteManifestTree.
+func LoadManifest(path string) (*Manifest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadManifestTree(path)
+	}
+
+	var manifest Manifest
+	if err := decodeManifestFile(path, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func decodeManifestFile(path string, out interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, out)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(out)
+	}
+	if err != nil {
+		return fmt.Errorf("reading manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadManifestTree(dir string) (*Manifest, error) {
+	manifest := &Manifest{}
+
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		path := filepath.Join(dir, manifestApplicationFile+ext)
+		if _, err := os.Stat(path); err == nil {
+			if err := decodeManifestFile(path, manifest); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	files := func(sub string) ([]string, error) {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if os.IsNotExist(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		paths := []string{}
+		for _, entry := range entries {
+			if !entry.IsDir() && isManifestFile(entry.Name()) {
+				paths = append(paths, filepath.Join(dir, sub, entry.Name()))
+			}
+		}
+		return paths, nil
+	}
+
+	roleFiles, err := files(manifestRolesDir)
+	if err != nil {
+		return nil, err
+	}
+	if roleFiles != nil && manifest.Roles == nil {
+		manifest.Roles = []ManifestRole{}
+	}
+	for _, path := range roleFiles {
+		var role ManifestRole
+		if err := decodeManifestFile(path, &role); err != nil {
+			return nil, err
+		}
+		manifest.Roles = append(manifest.Roles, role)
+	}
+
+	groupFiles, err := files(manifestGroupsDir)
+	if err != nil {
+		return nil, err
+	}
+	if groupFiles != nil && manifest.Groups == nil {
+		manifest.Groups = []ManifestGroup{}
+	}
+	for _, path := range groupFiles {
+		var group ManifestGroup
+		if err := decodeManifestFile(path, &group); err != nil {
+			return nil, err
+		}
+		manifest.Groups = append(manifest.Groups, group)
+	}
+
+	abilityFiles, err := files(manifestAbilitiesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range abilityFiles {
+		var ability ManifestAbility
+		if err := decodeManifestFile(path, &ability); err != nil {
+			return nil, err
+		}
+		manifest.FunctionalAbilities = append(manifest.FunctionalAbilities, ability)
+	}
+
+	manifest.Sort()
+	return manifest, nil
+}
+
+// WriteManifestTree writes one file per role, group and functional ability
+// under dir, plus an application file. Manifest files left over from a
+// previous export are removed so deleted objects disappear from the tree.
+func WriteManifestTree(dir string, manifest *Manifest, asJSON bool) error {
+	ext := ".yaml"
+	if asJSON {
+		ext = ".json"
+	}
+
+	write := func(path string, v interface{}) error {
+		data, err := MarshalManifest(v, asJSON)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0644)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := write(filepath.Join(dir, manifestApplicationFile+ext), Manifest{Application: manifest.Application}); err != nil {
+		return err
+	}
+
+	subdirs := map[string][]interface{}{}
+	names := map[string][]string{}
+	for _, role := range manifest.Roles {
+		subdirs[manifestRolesDir] = append(subdirs[manifestRolesDir], role)
+		names[manifestRolesDir] = append(names[manifestRolesDir], role.Name)
+	}
+	for _, group := range manifest.Groups {
+		subdirs[manifestGroupsDir] = append(subdirs[manifestGroupsDir], group)
+		names[manifestGroupsDir] = append(names[manifestGroupsDir], group.Name)
+	}
+	for _, ability := range manifest.FunctionalAbilities {
+		subdirs[manifestAbilitiesDir] = append(subdirs[manifestAbilitiesDir], ability)
+		names[manifestAbilitiesDir] = append(names[manifestAbilitiesDir], ability.Name)
+	}
+
+	for _, sub := range []string{manifestRolesDir, manifestGroupsDir, manifestAbilitiesDir} {
+		path := filepath.Join(dir, sub)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isManifestFile(entry.Name()) {
+				if err := os.Remove(filepath.Join(path, entry.Name())); err != nil {
+					return err
+				}
+			}
+		}
+
+		used := make(map[string]bool)
+		for i, v := range subdirs[sub] {
+			file := uniqueSlug(names[sub][i], used) + ext
+			if err := write(filepath.Join(path, file), v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isManifestFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// uniqueSlug turns a name into a file name such as "c3po-studio-a", adding a
+// numeric suffix when two names end up with the same slug.
+func uniqueSlug(name string, used map[string]bool) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		slug = "unnamed"
+	}
+
+	candidate := slug
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s-%d", slug, i)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// WriteManifest writes a manifest as YAML, or as JSON when path ends in ".json".
+func WriteManifest(path string, manifest *Manifest) error {
+	data, err := MarshalManifest(manifest, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// MarshalManifest encodes a manifest as YAML (or JSON), two-space indented.
+func MarshalManifest(manifest interface{}, asJSON bool) ([]byte, error) {
+	if asJSON {
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ValidateManifest applies the group naming convention and checks that names are
+// present and not repeated.
+func (c *Client) ValidateManifest(manifest *Manifest) error {
+	roles := make(map[string]bool)
+	for _, role := range manifest.Roles {
+		if err := c.ValidateRole(Role{Name: role.Name}); err != nil {
+			return err
+		}
+		key := strings.ToLower(role.Name)
+		if roles[key] {
+			return fmt.Errorf("role %q is declared twice", role.Name)
+		}
+		roles[key] = true
+	}
+
+	groups := make(map[string]bool)
+	for i, group := range manifest.Groups {
+		name, err := c.GroupName(group.Name)
+		if err != nil {
+			return err
+		}
+		manifest.Groups[i].Name = name
+
+		key := strings.ToLower(name)
+		if groups[key] {
+			return fmt.Errorf("group %q is declared twice", name)
+		}
+		groups[key] = true
+
+		for _, attr := range group.Attributes {
+			if strings.TrimSpace(attr.Name) == "" {
+				return fmt.Errorf("group %q has an attribute without a name", name)
+			}
+			if attr.Role != "" && group.Roles != nil && !containsFold(group.Roles, attr.Role) {
+				return fmt.Errorf("group %q: attribute %q targets role %q which is not bound to the group", name, attr.Name, attr.Role)
+			}
+		}
+	}
+	return nil
+}
+
+// Sort orders every list in the manifest by name so it serializes deterministically.
+func (m *Manifest) Sort() {
+	sort.SliceStable(m.Roles, func(i, j int) bool {
+		return strings.ToLower(m.Roles[i].Name) < strings.ToLower(m.Roles[j].Name)
+	})
+	for _, role := range m.Roles {
+		sortFold(role.FunctionalAbilities)
+	}
+
+	sort.SliceStable(m.FunctionalAbilities, func(i, j int) bool {
+		return strings.ToLower(m.FunctionalAbilities[i].Name) < strings.ToLower(m.FunctionalAbilities[j].Name)
+	})
+
+	sort.SliceStable(m.Groups, func(i, j int) bool {
+		return strings.ToLower(m.Groups[i].Name) < strings.ToLower(m.Groups[j].Name)
+	})
+	for _, group := range m.Groups {
+		sortFold(group.Roles)
+		sortFold(group.Members)
+		sort.SliceStable(group.Attributes, func(i, j int) bool {
+			if group.Attributes[i].Role != group.Attributes[j].Role {
+				return strings.ToLower(group.Attributes[i].Role) < strings.ToLower(group.Attributes[j].Role)
+			}
+			return strings.ToLower(group.Attributes[i].Name) < strings.ToLower(group.Attributes[j].Name)
+		})
+	}
+}
+
+// Manifest converts the state into a manifest that manages every role and group.
+func (s *State) Manifest() *Manifest {
+	manifest := &Manifest{Application: s.ApplicationId}
+
+	for _, role := range s.Roles {
+		manifestRole := ManifestRole{
+			Name:                  role.Name,
+			Description:           role.Description,
+			ConditionalExpression: ExpressionString(role.ConditionalExpression),
+			FunctionalAbilities:   []string{},
+		}
+		for _, ability := range s.RoleAbilities(role) {
+			manifestRole.FunctionalAbilities = append(manifestRole.FunctionalAbilities, ability.Name)
+		}
+		manifest.Roles = append(manifest.Roles, manifestRole)
+	}
+
+	for _, group := range s.Groups {
+		manifestGroup := ManifestGroup{
+			Name:       group.Group.Name,
+			Roles:      []string{},
+			Attributes: []ManifestAttribute{},
+			Members:    []string{},
+		}
+
+		bindings := make(map[string]string)
+		for _, binding := range group.Roles {
+			if role, ok := s.Role(binding.RoleId); ok {
+				manifestGroup.Roles = append(manifestGroup.Roles, role.Name)
+				bindings[binding.Id] = role.Name
+			}
+		}
+
+		for _, attr := range group.Attributes {
+			manifestGroup.Attributes = append(manifestGroup.Attributes, ManifestAttribute{
+				Name:  attr.AttributeName,
+				Value: attr.AttributeValue,
+				Role:  bindings[attr.GroupRoleId],
+			})
+		}
+
+		for _, user := range group.Members {
+			manifestGroup.Members = append(manifestGroup.Members, user.IdAtSourceSystem)
+		}
+
+		manifest.Groups = append(manifest.Groups, manifestGroup)
+	}
+
+	for _, ability := range s.FunctionalAbilities {
+		manifest.FunctionalAbilities = append(manifest.FunctionalAbilities, ManifestAbility{
+			Name:               ability.Name,
+			Description:        ability.Description,
+			DataClassification: ability.DataClassification,
+			SodRole:            ability.SodRole,
+			EntityAccess:       ability.FunctionalAbilityEntityAccess,
+		})
+	}
+
+	manifest.Sort()
+	return manifest
+}
+
+// ExpressionString returns a role's ConditionalExpression as text ("" when unset).
+func ExpressionString(expression interface{}) string {
+	switch v := expression.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+// expressionValue is the ConditionalExpression to send for the text of a
+// manifest: ExpressionString in reverse. A JSON tree is decoded back so that
+// Keystone keeps it as a tree and not as a string.
+func expressionValue(text string) interface{} {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return nil
+	}
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var tree interface{}
+		if err := json.Unmarshal([]byte(trimmed), &tree); err == nil {
+			return tree
+		}
+	}
+	return text
+}
+
+func sortFold(values []string) {
+	sort.SliceStable(values, func(i, j int) bool {
+		return strings.ToLower(values[i]) < strings.ToLower(values[j])
+	})
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MatchMode selects how a role or group name pattern is compared with names.
+type MatchMode int
+
+const (
+	// MatchAuto returns every name containing the pattern, ranked by how well it matches.
+	MatchAuto MatchMode = iota
+	// MatchExact returns names equal to the pattern, ignoring case.
+	MatchExact
+	// MatchGlob treats the pattern as a shell glob such as "studio*".
+	MatchGlob
+	// MatchRegex treats the pattern as a regular expression.
+	MatchRegex
+	// MatchFuzzy scores every name by similarity and keeps the close ones.
+	MatchFuzzy
+)
+
+var matchModeNames = map[MatchMode]string{
+	MatchAuto:  "auto",
+	MatchExact: "exact",
+	MatchGlob:  "glob",
+	MatchRegex: "regex",
+	MatchFuzzy: "fuzzy",
+}
+
+func (m MatchMode) String() string {
+	if name, ok := matchModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("MatchMode(%d)", int(m))
+}
+
+// ParseMatchMode converts a CLI value such as "glob" into a MatchMode.
+func ParseMatchMode(mode string) (MatchMode, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" || mode == "substring" {
+		return MatchAuto, nil
+	}
+	for m, name := range matchModeNames {
+		if name == mode {
+			return m, nil
+		}
+	}
+	return MatchAuto, fmt.Errorf("unknown match mode %q (expected auto, exact, glob, regex or fuzzy)", mode)
+}
+
+// MatchRank orders matches from best to worst.
+type MatchRank int
+
+const (
+	RankExact MatchRank = iota
+	RankPrefix
+	RankWord
+	RankSubstring
+	RankFuzzy
+	RankPattern
+)
+
+var matchRankNames = map[MatchRank]string{
+	RankExact:     "exact",
+	RankPrefix:    "prefix",
+	RankWord:      "word",
+	RankSubstring: "substring",
+	RankFuzzy:     "fuzzy",
+	RankPattern:   "pattern",
+}
+
+func (r MatchRank) String() string {
+	if name, ok := matchRankNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("MatchRank(%d)", int(r))
+}
+
+const (
+	// fuzzyThreshold is the lowest similarity MatchFuzzy returns.
+	fuzzyThreshold = 0.5
+	// suggestThreshold is the lowest similarity offered as a "did you mean".
+	suggestThreshold = 0.35
+)
+
+// nameMatch is a ranked hit on the name at Index of the slice being searched.
+// Score is only set for fuzzy matches, from 0 (unrelated) to 1 (identical).
+type nameMatch struct {
+	Index int
+	Name  string
+	Rank  MatchRank
+	Score float64
+}
+
+// rankName compares a name with the pattern (both already lowercased) and
+// reports the best rank it reaches in MatchAuto mode.
+func rankName(name, pattern string) (MatchRank, bool) {
+	switch {
+	case name == pattern:
+		return RankExact, true
+	case strings.HasPrefix(name, pattern):
+		return RankPrefix, true
+	}
+
+	idx := strings.Index(name, pattern)
+	if idx < 0 {
+		if isSubsequence(name, pattern) {
+			return RankFuzzy, true
+		}
+		return 0, false
+	}
+
+	for ; idx >= 0; idx = nextIndex(name, pattern, idx) {
+		if isWordStart(name, idx) {
+			return RankWord, true
+		}
+	}
+	return RankSubstring, true
+}
+
+func nextIndex(name, pattern string, from int) int {
+	next := strings.Index(name[from+1:], pattern)
+	if next < 0 {
+		return -1
+	}
+	return from + 1 + next
+}
+
+func isWordStart(s string, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	prev := rune(s[idx-1])
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
+
+// isSubsequence reports whether every non-space rune of pattern appears in name, in order.
+func isSubsequence(name, pattern string) bool {
+	pattern = strings.Join(strings.Fields(pattern), "")
+	if len(pattern) < 3 {
+		return false
+	}
+	runes := []rune(pattern)
+	i := 0
+	for _, r := range name {
+		if r == runes[i] {
+			i++
+			if i == len(runes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchNames filters and ranks names against pattern. A limit of zero or less
+// returns every match.
+func (c *Client) matchNames(names []string, pattern string, mode MatchMode, limit int) ([]nameMatch, error) {
+	var matches []nameMatch
+
+	switch mode {
+	case MatchGlob:
+		glob := strings.ToLower(pattern)
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+		for i, name := range names {
+			if ok, _ := path.Match(glob, strings.ToLower(name)); ok {
+				matches = append(matches, nameMatch{Index: i, Name: name, Rank: RankPattern})
+			}
+		}
+	case MatchRegex:
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+		for i, name := range names {
+			if re.MatchString(name) {
+				matches = append(matches, nameMatch{Index: i, Name: name, Rank: RankPattern})
+			}
+		}
+	case MatchFuzzy:
+		return c.fuzzyMatchNames(names, pattern, fuzzyThreshold, limit), nil
+	case MatchExact:
+		for i, name := range names {
+			if strings.EqualFold(c.reduceSpaces(name), c.reduceSpaces(pattern)) {
+				matches = append(matches, nameMatch{Index: i, Name: name, Rank: RankExact})
+			}
+		}
+	default:
+		lowerPattern := strings.ToLower(c.reduceSpaces(strings.TrimSpace(pattern)))
+		for i, name := range names {
+			if rank, ok := rankName(strings.ToLower(c.reduceSpaces(name)), lowerPattern); ok {
+				matches = append(matches, nameMatch{Index: i, Name: name, Rank: rank})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank < matches[j].Rank
+		}
+		if len(matches[i].Name) != len(matches[j].Name) {
+			return len(matches[i].Name) < len(matches[j].Name)
+		}
+		return strings.ToLower(matches[i].Name) < strings.ToLower(matches[j].Name)
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+// fuzzyMatchNames keeps the names scoring at least minScore against pattern,
+// most similar first.
+func (c *Client) fuzzyMatchNames(names []string, pattern string, minScore float64, limit int) []nameMatch {
+	var matches []nameMatch
+	for i, name := range names {
+		score := c.similarity(name, pattern)
+		if score >= minScore {
+			matches = append(matches, nameMatch{Index: i, Name: name, Rank: RankFuzzy, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return strings.ToLower(matches[i].Name) < strings.ToLower(matches[j].Name)
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+func (c *Client) normalizeName(name string) string {
+	return normalizeName(name)
+}
+
+// normalizeName lowercases a role or group name and drops the "C3PO -" prefix
+// and collapses runs of whitespace, so that only the meaningful characters are compared.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(reduceSpaces(removeC3POPrefixes(name))))
+}
+
+// similarity scores how alike two names are, from 0 to 1. It takes the better
+// of the whole-name edit distance and a per-word comparison, so both typos
+// ("Disny Studo") and partial names ("studio animation") score well.
+func (c *Client) similarity(name, pattern string) float64 {
+	a := c.normalizeName(name)
+	b := c.normalizeName(pattern)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	score := editSimilarity(a, b)
+	if tokens := tokenSimilarity(strings.Fields(a), strings.Fields(b)); tokens*0.9 > score {
+		score = tokens * 0.9
+	}
+	return score
+}
+
+// tokenSimilarity averages, over every word of the pattern, the best edit
+// similarity it reaches against any word of the name.
+func tokenSimilarity(nameTokens, patternTokens []string) float64 {
+	if len(nameTokens) == 0 || len(patternTokens) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, p := range patternTokens {
+		best := 0.0
+		for _, n := range nameTokens {
+			if sim := editSimilarity(n, p); sim > best {
+				best = sim
+			}
+		}
+		total += best
+	}
+	return total / float64(len(patternTokens))
+}
+
+// editSimilarity turns the Levenshtein distance into a 0..1 similarity.
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MemberResult is the outcome of adding or removing one user in a bulk change.
+type MemberResult struct {
+	HubID  string
+	User   User
+	Status string
+	Err    error
+}
+
+// ErrorMessage returns the error of a failed change, or "".
+func (r MemberResult) ErrorMessage() string {
+	if r.Err == nil {
+		return ""
+	}
+	return r.Err.Error()
+}
+
+// MarshalJSON writes Err as its message, since errors have no JSON form.
+func (r MemberResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		HubID  string
+		User   User
+		Status string
+		Error  string `json:",omitempty"`
+	}{r.HubID, r.User, r.Status, r.ErrorMessage()})
+}
+
+// Member change statuses reported in MemberResult.Status. Under --dry-run
+// nothing changes, and MemberWouldAdd / MemberWouldRemove are reported instead.
+const (
+	MemberAdded          = "added"
+	MemberRemoved        = "removed"
+	MemberWouldAdd       = "would add"
+	MemberWouldRemove    = "would remove"
+	MemberAlreadyPresent = "already a member"
+	MemberNotPresent     = "not a member"
+	MemberFailed         = "failed"
+)
+
+// ErrUserNotFound is returned (wrapped) by LookupUser for an unknown HubID.
+var ErrUserNotFound = errors.New("not found")
+
+// LookupUser returns the Keystone user for a HubID.
+func (c *Client) LookupUser(hubid string) (User, error) {
+	hubid = strings.TrimSpace(hubid)
+	if hubid == "" {
+		return User{}, fmt.Errorf("HubID cannot be empty")
+	}
+
+	var users []User
+	err := c.keystone("GET", "adminservice/keystone/v1/user", "idAtSourceSystem="+url.QueryEscape(hubid), nil, &users)
+	if err != nil {
+		return User{}, err
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.IdAtSourceSystem, hubid) {
+			return user, nil
+		}
+	}
+	return User{}, fmt.Errorf("user %q %w", hubid, ErrUserNotFound)
+}
+
+// ListGroupMembers returns the users in a group, sorted by HubID.
+func (c *Client) ListGroupMembers(groupId string) ([]User, error) {
+	var users []User
+	err := c.keystone("GET", "adminservice/keystone/v1/group/"+groupId+"/user", "", nil, &users)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return strings.ToLower(users[i].IdAtSourceSystem) < strings.ToLower(users[j].IdAtSourceSystem)
+	})
+	return users, nil
+}
+
+// AddGroupMember adds a user (by Keystone user id) to a group.
+func (c *Client) AddGroupMember(groupId string, userId string) error {
+	return c.keystone("POST", "adminservice/keystone/v1/group/"+groupId+"/user", "", map[string]string{"UserId": userId}, nil)
+}
+
+// RemoveGroupMember removes a user (by Keystone user id) from a group.
+func (c *Client) RemoveGroupMember(groupId string, userId string) error {
+	return c.keystone("DELETE", "adminservice/keystone/v1/group/"+groupId+"/user/"+userId, "", nil, nil)
+}
+
+// AddGroupMembers adds every HubID to the group. Each user must exist and be
+// active; users already in the group are left alone. One result is returned
+// per HubID, in order.
+func (c *Client) AddGroupMembers(groupId string, hubids []string) ([]MemberResult, error) {
+	members, err := c.memberIndex(groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []MemberResult
+	for _, hubid := range hubids {
+		result := MemberResult{HubID: hubid}
+
+		user, err := c.LookupUser(hubid)
+		switch {
+		case err != nil:
+			result.Status, result.Err = MemberFailed, err
+		case !user.IsActive:
+			result.User = user
+			result.Status, result.Err = MemberFailed, fmt.Errorf("user %q is not active", hubid)
+		case members[user.Id]:
+			result.User, result.Status = user, MemberAlreadyPresent
+		default:
+			result.User = user
+			if err := c.AddGroupMember(groupId, user.Id); err != nil {
+				result.Status, result.Err = MemberFailed, err
+			} else {
+				result.Status = MemberAdded
+				if c.dryRun {
+					result.Status = MemberWouldAdd
+				}
+				members[user.Id] = true
+			}
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// RemoveGroupMembers removes every HubID from the group. Inactive users may
+// be removed; users not in the group are reported but not treated as errors.
+func (c *Client) RemoveGroupMembers(groupId string, hubids []string) ([]MemberResult, error) {
+	members, err := c.memberIndex(groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []MemberResult
+	for _, hubid := range hubids {
+		result := MemberResult{HubID: hubid}
+
+		user, err := c.LookupUser(hubid)
+		switch {
+		case err != nil:
+			result.Status, result.Err = MemberFailed, err
+		case !members[user.Id]:
+			result.User, result.Status = user, MemberNotPresent
+		default:
+			result.User = user
+			if err := c.RemoveGroupMember(groupId, user.Id); err != nil {
+				result.Status, result.Err = MemberFailed, err
+			} else {
+				result.Status = MemberRemoved
+				if c.dryRun {
+					result.Status = MemberWouldRemove
+				}
+				delete(members, user.Id)
+			}
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (c *Client) memberIndex(groupId string) (map[string]bool, error) {
+	users, err := c.ListGroupMembers(groupId)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]bool, len(users))
+	for _, user := range users {
+		members[user.Id] = true
+	}
+	return members, nil
+}
@@ -0,0 +1,420 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by ParseOutput. FormatText is the historical,
+// human-oriented output of each command.
+const (
+	FormatText     = "text"
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatYAML     = "yaml"
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+	FormatTemplate = "template"
+)
+
+// Output is how command results are printed.
+type Output struct {
+	Format    string
+	Template  *template.Template
+	Fields    []string
+	NoHeaders bool
+	Query     *Query
+	Writer    io.Writer
+}
+
+// Column is one named field of the printed items, used by the table, csv and
+// tsv formats and selected with Output.Fields.
+type Column struct {
+	Name  string
+	Value func(item interface{}) string
+}
+
+var output = &Output{Format: FormatText, Writer: os.Stdout}
+
+// SetOutput changes how every Print function writes results.
+func SetOutput(o *Output) {
+	if o.Writer == nil {
+		o.Writer = os.Stdout
+	}
+	output = o
+}
+
+// TextOutput reports whether results are printed as human-oriented text, in
+// which case commands may add headings and hints around them.
+func TextOutput() bool {
+	return output.Format == FormatText && output.Query == nil
+}
+
+// ParseOutput parses an output format: text, table, json, ndjson, yaml, csv,
+// tsv or template=<Go template>. fields selects columns by name and query, when
+// not empty, is compiled with ParseQuery.
+func ParseOutput(format string, fields []string, noHeaders bool, query string) (*Output, error) {
+	o := &Output{Format: strings.ToLower(strings.TrimSpace(format)), NoHeaders: noHeaders, Writer: os.Stdout}
+	if strings.TrimSpace(query) != "" {
+		q, err := ParseQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		o.Query = q
+	}
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			o.Fields = append(o.Fields, field)
+		}
+	}
+
+	if name, text, ok := strings.Cut(format, "="); ok && strings.EqualFold(strings.TrimSpace(name), FormatTemplate) {
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		tmpl, err := template.New("output").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output template: %w", err)
+		}
+		o.Format = FormatTemplate
+		o.Template = tmpl
+		return o, nil
+	}
+
+	switch o.Format {
+	case "":
+		o.Format = FormatText
+	case FormatText, FormatTable, FormatJSON, FormatNDJSON, FormatYAML, FormatCSV, FormatTSV:
+	case FormatTemplate:
+		return nil, fmt.Errorf("output template missing: use -o template='{{.Name}}'")
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected text, table, json, ndjson, yaml, csv, tsv or template=...)", format)
+	}
+	return o, nil
+}
+
+// PrintItems prints a slice of results in the selected output format. text
+// prints them the historical way and is used for FormatText. With a query,
+// its results are printed instead of the items.
+func PrintItems(items interface{}, columns []Column, text func()) error {
+	if output.Query != nil {
+		return output.printQuery(items)
+	}
+	if output.Format == FormatText {
+		text()
+		return nil
+	}
+	return output.Print(items, columns)
+}
+
+// Print writes items, a slice, in the output's format.
+func (o *Output) Print(items interface{}, columns []Column) error {
+	rows := itemList(items)
+
+	columns, err := o.selectColumns(columns)
+	if err != nil {
+		return err
+	}
+
+	switch o.Format {
+	case FormatTable:
+		w := tabwriter.NewWriter(o.Writer, 0, 0, 2, ' ', 0)
+		if !o.NoHeaders {
+			var names []string
+			for _, column := range columns {
+				names = append(names, strings.ToUpper(column.Name))
+			}
+			fmt.Fprintln(w, strings.Join(names, "\t"))
+		}
+		for _, row := range rows {
+			var values []string
+			for _, column := range columns {
+				values = append(values, strings.ReplaceAll(column.Value(row), "\t", " "))
+			}
+			fmt.Fprintln(w, strings.Join(values, "\t"))
+		}
+		return w.Flush()
+
+	case FormatCSV, FormatTSV:
+		w := csv.NewWriter(o.Writer)
+		if o.Format == FormatTSV {
+			w.Comma = '\t'
+		}
+		if !o.NoHeaders {
+			var names []string
+			for _, column := range columns {
+				names = append(names, column.Name)
+			}
+			w.Write(names)
+		}
+		for _, row := range rows {
+			var values []string
+			for _, column := range columns {
+				values = append(values, column.Value(row))
+			}
+			w.Write(values)
+		}
+		w.Flush()
+		return w.Error()
+
+	case FormatJSON:
+		values, err := o.values(rows, columns)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(o.Writer, string(data))
+		return err
+
+	case FormatNDJSON:
+		values, err := o.values(rows, columns)
+		if err != nil {
+			return err
+		}
+		for _, value := range values {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(o.Writer, string(data)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatYAML:
+		values, err := o.values(rows, columns)
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(values)
+		if err != nil {
+			return err
+		}
+		_, err = o.Writer.Write(data)
+		return err
+
+	case FormatTemplate:
+		for _, row := range rows {
+			if err := o.Template.Execute(o.Writer, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown output format %q", o.Format)
+}
+
+// printQuery runs the query on the JSON form of items, a slice, and prints its
+// results. A single result that is not an array is printed as is by the text,
+// json and yaml formats; otherwise the results are printed as rows whose
+// columns are the keys of the objects among them. Text output is JSON, since
+// the results are no longer typed.
+func (o *Output) printQuery(items interface{}) error {
+	results, err := o.Query.Run(itemList(items))
+	if err != nil {
+		return err
+	}
+
+	var rows []interface{}
+	if len(results) == 1 {
+		if list, ok := results[0].([]interface{}); ok {
+			rows = list
+		}
+	}
+	single := len(results) == 1 && rows == nil
+	if !single && rows == nil {
+		rows = results
+	}
+
+	format := o.Format
+	if format == FormatText {
+		format = FormatJSON
+	}
+	if single && len(o.Fields) == 0 && (format == FormatJSON || format == FormatYAML) {
+		var data []byte
+		if format == FormatJSON {
+			data, err = json.MarshalIndent(results[0], "", "  ")
+			data = append(data, '\n')
+		} else {
+			data, err = yaml.Marshal(results[0])
+		}
+		if err != nil {
+			return err
+		}
+		_, err = o.Writer.Write(data)
+		return err
+	}
+	if single {
+		rows = results
+	}
+
+	queried := *o
+	queried.Format = format
+	return queried.Print(rows, queryColumns(rows))
+}
+
+// queryColumns returns a column per key of the objects in rows, sorted, or a
+// single Value column when the rows are not objects.
+func queryColumns(rows []interface{}) []Column {
+	seen := make(map[string]bool)
+	var keys []string
+	scalars := false
+	for _, row := range rows {
+		object, ok := row.(map[string]interface{})
+		if !ok {
+			scalars = true
+			continue
+		}
+		for key := range object {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var columns []Column
+	if scalars || len(keys) == 0 {
+		columns = append(columns, Column{Name: "Value", Value: func(v interface{}) string {
+			if _, ok := v.(map[string]interface{}); ok {
+				return ""
+			}
+			return scalarString(v)
+		}})
+	}
+	for _, key := range keys {
+		key := key
+		columns = append(columns, Column{Name: key, Value: func(v interface{}) string {
+			object, _ := v.(map[string]interface{})
+			return scalarString(object[key])
+		}})
+	}
+	return columns
+}
+
+// selectColumns returns the columns named in Fields, in that order, or every
+// column when no fields were given.
+func (o *Output) selectColumns(columns []Column) ([]Column, error) {
+	if len(o.Fields) == 0 {
+		return columns, nil
+	}
+
+	var selected []Column
+	for _, field := range o.Fields {
+		found := false
+		for _, column := range columns {
+			if strings.EqualFold(column.Name, field) {
+				selected = append(selected, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			var names []string
+			for _, column := range columns {
+				names = append(names, column.Name)
+			}
+			return nil, fmt.Errorf("unknown field %q (available: %s)", field, strings.Join(names, ", "))
+		}
+	}
+	return selected, nil
+}
+
+// values converts rows to generic JSON values, so that JSON and YAML use the
+// same field names. With Fields, only the selected columns are kept.
+func (o *Output) values(rows []interface{}, columns []Column) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if len(o.Fields) > 0 {
+			fields := make(record, 0, len(columns))
+			for _, column := range columns {
+				fields = append(fields, recordField{column.Name, column.Value(row)})
+			}
+			values = append(values, fields)
+			continue
+		}
+
+		data, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// record is a row reduced to the --fields columns. Unlike a map, it keeps the
+// columns in the order they were given when written as JSON or YAML.
+type record []recordField
+
+type recordField struct {
+	Name  string
+	Value string
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (r record) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, field := range r {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field.Name},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field.Value})
+	}
+	return node, nil
+}
+
+func itemList(items interface{}) []interface{} {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice {
+		return []interface{}{items}
+	}
+	rows := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		rows = append(rows, v.Index(i).Interface())
+	}
+	return rows
+}
@@ -0,0 +1,604 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeAction is what a planned change does to its object.
+type ChangeAction string
+
+const (
+	ChangeCreate ChangeAction = "create"
+	ChangeUpdate ChangeAction = "update"
+	ChangeDelete ChangeAction = "delete"
+)
+
+// Kinds of objects a Change applies to.
+const (
+	KindRole      = "role"
+	KindGroup     = "group"
+	KindGroupRole = "group role"
+	KindAttribute = "attribute"
+	KindMember    = "member"
+)
+
+// Phases in which changes are applied, so that objects exist before anything
+// refers to them and references are removed before what they refer to.
+const (
+	phaseRoleWrite = iota
+	phaseGroupCreate
+	phaseGroupRoleCreate
+	phaseAttributeWrite
+	phaseMemberAdd
+	phaseMemberRemove
+	phaseAttributeDelete
+	phaseGroupRoleDelete
+	phaseGroupDelete
+	phaseRoleDelete
+)
+
+// Change is one create, update or delete in a Plan.
+type Change struct {
+	Action  ChangeAction
+	Kind    string
+	Target  string
+	Details []string
+
+	phase int
+	apply func(*applier) error
+}
+
+// Plan is the ordered list of changes that turns the current state into a manifest.
+type Plan struct {
+	Changes []Change
+}
+
+// CountChanges returns how many changes create, update and delete objects.
+func CountChanges(changes []Change) (created, updated, deleted int) {
+	for _, change := range changes {
+		switch change.Action {
+		case ChangeCreate:
+			created++
+		case ChangeUpdate:
+			updated++
+		case ChangeDelete:
+			deleted++
+		}
+	}
+	return created, updated, deleted
+}
+
+// applier carries the ids of objects, including the ones created while a plan
+// is being applied, keyed by lowercased name.
+type applier struct {
+	client   *Client
+	roles    map[string]string
+	groups   map[string]string
+	bindings map[string]string
+}
+
+func bindingKey(group string, role string) string {
+	return strings.ToLower(group) + "\x00" + strings.ToLower(role)
+}
+
+// newId returns id, or a readable placeholder when Keystone did not return one
+// (in dry-run mode nothing is created).
+func newId(id string, name string) string {
+	if id == "" {
+		return "<new:" + name + ">"
+	}
+	return id
+}
+
+// PruneMode selects what PlanManifest does with the roles and groups that are
+// not in the manifest.
+type PruneMode int
+
+const (
+	// PruneNone leaves them alone.
+	PruneNone PruneMode = iota
+	// PruneMissing deletes them, for each kind whose section (roles, groups) is
+	// in the manifest, but never every role or every group.
+	PruneMissing
+	// PruneAll also deletes every role or group when the section is empty.
+	PruneAll
+)
+
+// ErrPruneEverything is returned (wrapped) by PlanManifest when PruneMissing
+// would delete every role or every group.
+var ErrPruneEverything = errors.New("would delete every one of them")
+
+// PlanManifest compares the desired manifest with the current state and returns
+// the changes needed, in the order they must be applied. Roles and groups that
+// are not in the manifest are only deleted as prune allows.
+func (c *Client) PlanManifest(desired *Manifest, current *State, prune PruneMode) (*Plan, error) {
+	if err := c.ValidateManifest(desired); err != nil {
+		return nil, err
+	}
+	return c.planManifest(desired, current, prune, false)
+}
+
+// planManifest is PlanManifest without validating the manifest. With raw, roles
+// and groups are created exactly as named and expressions are not checked, as
+// restore needs.
+func (c *Client) planManifest(desired *Manifest, current *State, prune PruneMode, raw bool) (*Plan, error) {
+	plan := &Plan{}
+	add := func(change Change) {
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	desiredRoles := make(map[string]bool)
+	for _, want := range desired.Roles {
+		desiredRoles[strings.ToLower(want.Name)] = true
+
+		var abilityIds []string
+		for _, name := range want.FunctionalAbilities {
+			ability, ok := current.FunctionalAbilityByName(name)
+			if !ok {
+				return nil, fmt.Errorf("role %q: functional ability %q not found", want.Name, name)
+			}
+			abilityIds = append(abilityIds, ability.Id)
+		}
+		sort.Strings(abilityIds)
+
+		have, exists := current.RoleByName(want.Name)
+		if !exists {
+			role := Role{
+				Name:                    want.Name,
+				Description:             want.Description,
+				ConditionalExpression:   expressionValue(want.ConditionalExpression),
+				RoleFunctionalAbilities: RoleAbilities(abilityIds),
+			}
+
+			add(Change{
+				Action:  ChangeCreate,
+				Kind:    KindRole,
+				Target:  want.Name,
+				Details: roleDetails(want),
+				phase:   phaseRoleWrite,
+				apply: func(a *applier) error {
+					create := a.client.CreateRole
+					if raw {
+						create = a.client.createRole
+					}
+					created, err := create(role)
+					if err != nil {
+						return err
+					}
+					a.roles[strings.ToLower(role.Name)] = newId(created.Id, role.Name)
+					return nil
+				},
+			})
+			continue
+		}
+
+		role := have
+		var details []string
+		if want.Description != have.Description {
+			details = append(details, fmt.Sprintf("description: %q => %q", have.Description, want.Description))
+			role.Description = want.Description
+		}
+		if haveExpression := ExpressionString(have.ConditionalExpression); want.ConditionalExpression != haveExpression {
+			details = append(details, fmt.Sprintf("conditionalExpression: %q => %q", haveExpression, want.ConditionalExpression))
+			if !raw {
+				if err := ValidateExpression(expressionValue(want.ConditionalExpression)); err != nil {
+					return nil, fmt.Errorf("role %q: %w", want.Name, err)
+				}
+			}
+			role.ConditionalExpression = expressionValue(want.ConditionalExpression)
+		}
+		if want.FunctionalAbilities != nil {
+			var haveNames []string
+			for _, ability := range current.RoleAbilities(have) {
+				haveNames = append(haveNames, ability.Name)
+			}
+			added, removed := diffNames(haveNames, want.FunctionalAbilities)
+			for _, name := range added {
+				details = append(details, "+ functional ability "+name)
+			}
+			for _, name := range removed {
+				details = append(details, "- functional ability "+name)
+			}
+			if len(added) > 0 || len(removed) > 0 {
+				role.RoleFunctionalAbilities = RoleAbilities(abilityIds)
+			}
+		}
+
+		if len(details) > 0 {
+			add(Change{
+				Action:  ChangeUpdate,
+				Kind:    KindRole,
+				Target:  have.Name,
+				Details: details,
+				phase:   phaseRoleWrite,
+				apply: func(a *applier) error {
+					return a.client.UpdateRole(role)
+				},
+			})
+		}
+	}
+
+	desiredGroups := make(map[string]bool)
+	for _, want := range desired.Groups {
+		desiredGroups[groupKey(want.Name)] = true
+
+		have, exists := current.GroupByName(want.Name)
+		groupName := want.Name
+		if exists {
+			groupName = have.Group.Name
+		} else {
+			add(Change{
+				Action: ChangeCreate,
+				Kind:   KindGroup,
+				Target: groupName,
+				phase:  phaseGroupCreate,
+				apply: func(a *applier) error {
+					create := a.client.CreateGroup
+					if raw {
+						create = a.client.createGroup
+					}
+					created, err := create(groupName)
+					if err != nil {
+						return err
+					}
+					a.groups[strings.ToLower(groupName)] = newId(created.Id, groupName)
+					return nil
+				},
+			})
+		}
+
+		if err := c.planGroupRoles(plan, want, have, groupName, current, desiredRoles); err != nil {
+			return nil, err
+		}
+		c.planGroupAttributes(plan, want, have, groupName, current)
+		c.planGroupMembers(plan, want, have, groupName)
+	}
+
+	// A section absent from the manifest (nil) is not managed; an empty one
+	// means "none", which is only honoured with PruneAll.
+	pruneGroups := prune != PruneNone && desired.Groups != nil
+	pruneRoles := prune != PruneNone && desired.Roles != nil
+	if prune != PruneAll {
+		if pruneGroups && len(desired.Groups) == 0 && len(current.Groups) > 0 {
+			return nil, fmt.Errorf("the manifest has no groups: pruning %d group(s) %w", len(current.Groups), ErrPruneEverything)
+		}
+		if pruneRoles && len(desired.Roles) == 0 && len(current.Roles) > 0 {
+			return nil, fmt.Errorf("the manifest has no roles: pruning %d role(s) %w", len(current.Roles), ErrPruneEverything)
+		}
+	}
+
+	if pruneGroups {
+		for _, have := range current.Groups {
+			if desiredGroups[groupKey(have.Group.Name)] {
+				continue
+			}
+			group := have.Group
+			add(Change{
+				Action:  ChangeDelete,
+				Kind:    KindGroup,
+				Target:  group.Name,
+				Details: []string{fmt.Sprintf("%d member(s), %d role(s)", len(have.Members), len(have.Roles))},
+				phase:   phaseGroupDelete,
+				apply: func(a *applier) error {
+					return a.client.DeleteGroup(group.Id)
+				},
+			})
+		}
+	}
+
+	if pruneRoles {
+		for _, have := range current.Roles {
+			if desiredRoles[strings.ToLower(have.Name)] {
+				continue
+			}
+			role := have
+			add(Change{
+				Action: ChangeDelete,
+				Kind:   KindRole,
+				Target: role.Name,
+				phase:  phaseRoleDelete,
+				apply: func(a *applier) error {
+					return a.client.DeleteRole(role.Id)
+				},
+			})
+		}
+	}
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].phase < plan.Changes[j].phase
+	})
+	return plan, nil
+}
+
+func (c *Client) planGroupRoles(plan *Plan, want ManifestGroup, have GroupState, groupName string, current *State, desiredRoles map[string]bool) error {
+	if want.Roles == nil {
+		return nil
+	}
+
+	for _, name := range want.Roles {
+		if _, ok := current.RoleByName(name); !ok && !desiredRoles[strings.ToLower(name)] {
+			return fmt.Errorf("group %q: role %q not found", groupName, name)
+		}
+	}
+
+	var haveNames []string
+	bindings := make(map[string]GroupRole)
+	for _, binding := range have.Roles {
+		if role, ok := current.Role(binding.RoleId); ok {
+			haveNames = append(haveNames, role.Name)
+			bindings[strings.ToLower(role.Name)] = binding
+		}
+	}
+
+	added, removed := diffNames(haveNames, want.Roles)
+	for _, name := range added {
+		roleName := name
+		plan.Changes = append(plan.Changes, Change{
+			Action: ChangeCreate,
+			Kind:   KindGroupRole,
+			Target: groupName + " / " + roleName,
+			phase:  phaseGroupRoleCreate,
+			apply: func(a *applier) error {
+				created, err := a.client.AddGroupRole(a.groups[strings.ToLower(groupName)], a.roles[strings.ToLower(roleName)])
+				if err != nil {
+					return err
+				}
+				a.bindings[bindingKey(groupName, roleName)] = newId(created.Id, groupName+" / "+roleName)
+				return nil
+			},
+		})
+	}
+	for _, name := range removed {
+		binding := bindings[strings.ToLower(name)]
+		plan.Changes = append(plan.Changes, Change{
+			Action: ChangeDelete,
+			Kind:   KindGroupRole,
+			Target: groupName + " / " + name,
+			phase:  phaseGroupRoleDelete,
+			apply: func(a *applier) error {
+				return a.client.UnassignRole(binding.GroupId, binding.Id)
+			},
+		})
+	}
+	return nil
+}
+
+func (c *Client) planGroupAttributes(plan *Plan, want ManifestGroup, have GroupState, groupName string, current *State) {
+	if want.Attributes == nil {
+		return
+	}
+
+	bindingRoles := make(map[string]string)
+	for _, binding := range have.Roles {
+		if role, ok := current.Role(binding.RoleId); ok {
+			bindingRoles[binding.Id] = role.Name
+		}
+	}
+
+	existing := make(map[string]GroupAttributes)
+	for _, attr := range have.Attributes {
+		existing[bindingKey(bindingRoles[attr.GroupRoleId], attr.AttributeName)] = attr
+	}
+
+	wanted := make(map[string]bool)
+	for _, want := range want.Attributes {
+		attrWant := want
+		key := bindingKey(attrWant.Role, attrWant.Name)
+		wanted[key] = true
+		target := groupName + " / " + attributeLabel(attrWant.Role, attrWant.Name)
+
+		attr, ok := existing[key]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{
+				Action:  ChangeCreate,
+				Kind:    KindAttribute,
+				Target:  target,
+				Details: []string{fmt.Sprintf("value: %q", attrWant.Value)},
+				phase:   phaseAttributeWrite,
+				apply: func(a *applier) error {
+					attr := GroupAttributes{
+						AttributeName:  attrWant.Name,
+						AttributeValue: attrWant.Value,
+						GroupId:        a.groups[strings.ToLower(groupName)],
+						UsageType:      UsageTypeGroup,
+					}
+					if attrWant.Role != "" {
+						attr.GroupRoleId = a.bindings[bindingKey(groupName, attrWant.Role)]
+						attr.RoleId = a.roles[strings.ToLower(attrWant.Role)]
+						attr.UsageType = UsageTypeGroupRole
+					}
+					_, err := a.client.AddGroupAttribute(attr)
+					return err
+				},
+			})
+			continue
+		}
+
+		if attr.AttributeValue != attrWant.Value {
+			updated := attr
+			updated.AttributeValue = attrWant.Value
+			plan.Changes = append(plan.Changes, Change{
+				Action:  ChangeUpdate,
+				Kind:    KindAttribute,
+				Target:  target,
+				Details: []string{fmt.Sprintf("value: %q => %q", attr.AttributeValue, attrWant.Value)},
+				phase:   phaseAttributeWrite,
+				apply: func(a *applier) error {
+					return a.client.UpdateGroupAttribute(updated)
+				},
+			})
+		}
+	}
+
+	for key, attr := range existing {
+		if wanted[key] {
+			continue
+		}
+		remove := attr
+		plan.Changes = append(plan.Changes, Change{
+			Action: ChangeDelete,
+			Kind:   KindAttribute,
+			Target: groupName + " / " + attributeLabel(bindingRoles[attr.GroupRoleId], attr.AttributeName),
+			phase:  phaseAttributeDelete,
+			apply: func(a *applier) error {
+				return a.client.DeleteGroupAttribute(remove.GroupId, remove.Id)
+			},
+		})
+	}
+}
+
+func (c *Client) planGroupMembers(plan *Plan, want ManifestGroup, have GroupState, groupName string) {
+	if want.Members == nil {
+		return
+	}
+
+	var haveHubIDs []string
+	users := make(map[string]User)
+	for _, user := range have.Members {
+		haveHubIDs = append(haveHubIDs, user.IdAtSourceSystem)
+		users[strings.ToLower(user.IdAtSourceSystem)] = user
+	}
+
+	added, removed := diffNames(haveHubIDs, want.Members)
+	for _, hubid := range added {
+		memberHubID := hubid
+		plan.Changes = append(plan.Changes, Change{
+			Action: ChangeCreate,
+			Kind:   KindMember,
+			Target: groupName + " / " + memberHubID,
+			phase:  phaseMemberAdd,
+			apply: func(a *applier) error {
+				user, err := a.client.LookupUser(memberHubID)
+				if err != nil {
+					return err
+				}
+				if !user.IsActive {
+					return fmt.Errorf("user %q is not active", memberHubID)
+				}
+				return a.client.AddGroupMember(a.groups[strings.ToLower(groupName)], user.Id)
+			},
+		})
+	}
+	for _, hubid := range removed {
+		user := users[strings.ToLower(hubid)]
+		groupId := have.Group.Id
+		plan.Changes = append(plan.Changes, Change{
+			Action: ChangeDelete,
+			Kind:   KindMember,
+			Target: groupName + " / " + hubid,
+			phase:  phaseMemberRemove,
+			apply: func(a *applier) error {
+				return a.client.RemoveGroupMember(groupId, user.Id)
+			},
+		})
+	}
+}
+
+// ApplyPlan executes the plan in order against the state it was computed from,
+// calling progress after every change. It stops at the first failure and
+// returns the changes that were applied.
+func (c *Client) ApplyPlan(plan *Plan, current *State, progress func(Change, error)) ([]Change, error) {
+	a := c.newApplier(current)
+	var applied []Change
+	for _, change := range plan.Changes {
+		err := change.apply(a)
+		if progress != nil {
+			progress(change, err)
+		}
+		if err != nil {
+			return applied, fmt.Errorf("%s %s %q: %w", change.Action, change.Kind, change.Target, err)
+		}
+		applied = append(applied, change)
+	}
+	return applied, nil
+}
+
+// ApplyPlanAll is ApplyPlan going on after a failure, so that one change that
+// cannot be made does not hold back the others. It returns the changes that
+// were applied and the ones that failed.
+func (c *Client) ApplyPlanAll(plan *Plan, current *State, progress func(Change, error)) (applied []Change, failed []Change) {
+	a := c.newApplier(current)
+	for _, change := range plan.Changes {
+		err := change.apply(a)
+		if progress != nil {
+			progress(change, err)
+		}
+		if err != nil {
+			failed = append(failed, change)
+		} else {
+			applied = append(applied, change)
+		}
+	}
+	return applied, failed
+}
+
+// newApplier returns an applier holding the ids of the objects in current.
+func (c *Client) newApplier(current *State) *applier {
+	a := &applier{
+		client:   c,
+		roles:    make(map[string]string),
+		groups:   make(map[string]string),
+		bindings: make(map[string]string),
+	}
+	for _, role := range current.Roles {
+		a.roles[strings.ToLower(role.Name)] = role.Id
+	}
+	for _, group := range current.Groups {
+		a.groups[strings.ToLower(group.Group.Name)] = group.Group.Id
+		for _, binding := range group.Roles {
+			if role, ok := current.Role(binding.RoleId); ok {
+				a.bindings[bindingKey(group.Group.Name, role.Name)] = binding.Id
+			}
+		}
+	}
+	return a
+}
+
+func roleDetails(role ManifestRole) []string {
+	var details []string
+	if role.Description != "" {
+		details = append(details, fmt.Sprintf("description: %q", role.Description))
+	}
+	if role.ConditionalExpression != "" {
+		details = append(details, fmt.Sprintf("conditionalExpression: %q", role.ConditionalExpression))
+	}
+	for _, name := range role.FunctionalAbilities {
+		details = append(details, "+ functional ability "+name)
+	}
+	return details
+}
+
+func attributeLabel(role string, name string) string {
+	if role == "" {
+		return name
+	}
+	return role + " / " + name
+}
+
+// diffNames compares two name lists case-insensitively and returns the names
+// only in want (added) and only in have (removed), sorted.
+func diffNames(have []string, want []string) (added []string, removed []string) {
+	haveSet := make(map[string]bool, len(have))
+	for _, name := range have {
+		haveSet[strings.ToLower(name)] = true
+	}
+	wantSet := make(map[string]bool, len(want))
+	for _, name := range want {
+		key := strings.ToLower(name)
+		if !haveSet[key] && !wantSet[key] {
+			added = append(added, name)
+		}
+		wantSet[key] = true
+	}
+	for _, name := range have {
+		if !wantSet[strings.ToLower(name)] {
+			removed = append(removed, name)
+		}
+	}
+	sortFold(added)
+	sortFold(removed)
+	return added, removed
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+)
+
+func planTargets(plan *Plan, action ChangeAction, kind string) []string {
+	var targets []string
+	for _, change := range plan.Changes {
+		if change.Action == action && change.Kind == kind {
+			targets = append(targets, change.Target)
+		}
+	}
+	return targets
+}
+
+func TestPlanManifestPruneLegacyGroupName(t *testing.T) {
+	current := &State{
+		Groups: []GroupState{
+			{Group: Group{Id: "g1", Name: "C3PO-Studio A"}},
+			{Group: Group{Id: "g2", Name: "C3PO - Studio B"}},
+		},
+	}
+	desired := &Manifest{
+		Groups: []ManifestGroup{{Name: "C3PO - Studio A"}},
+	}
+
+	plan, err := (&Client{}).PlanManifest(desired, current, PruneMissing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created := planTargets(plan, ChangeCreate, KindGroup); len(created) != 0 {
+		t.Errorf("created groups %q, want none", created)
+	}
+	deleted := planTargets(plan, ChangeDelete, KindGroup)
+	if len(deleted) != 1 || deleted[0] != "C3PO - Studio B" {
+		t.Errorf("deleted groups %q, want [\"C3PO - Studio B\"]", deleted)
+	}
+}
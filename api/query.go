@@ -0,0 +1,1248 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a compiled jq-style expression, evaluated against the JSON form of
+// a command's result. The supported subset is:
+//
+//	.  .Name  ."Key"  .[0]  .[1:3]  .[]  .Name?      paths and iteration
+//	a | b   a, b   (a)                                pipes, alternatives, grouping
+//	[ a ]   { Name, id: .Id, "x": a }                 array and object construction
+//	==  !=  <  <=  >  >=  and  or                     comparisons and logic
+//	"string"  42  -1  true  false  null               literals
+//
+// and the functions length, keys, values, map(f), select(f), has(k),
+// contains(x), test(re), startswith(s), endswith(s), ascii_downcase,
+// ascii_upcase, sort, sort_by(f), unique, first, last, join(s), not,
+// tostring, type, add and empty.
+type Query struct {
+	source string
+	root   queryNode
+}
+
+// ParseQuery compiles a query expression.
+func ParseQuery(source string) (*Query, error) {
+	tokens, err := lexQuery(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", source, err)
+	}
+
+	p := &queryParser{tokens: tokens}
+	root, err := p.parsePipe()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", source, err)
+	}
+	return &Query{source: source, root: root}, nil
+}
+
+// String returns the query source.
+func (q *Query) String() string {
+	return q.source
+}
+
+// Run evaluates the query against the JSON form of v and returns every result.
+func (q *Query) Run(v interface{}) ([]interface{}, error) {
+	input, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	results, err := q.root.eval(input)
+	if err != nil {
+		return nil, fmt.Errorf("query %q: %w", q.source, err)
+	}
+	return results, nil
+}
+
+// jsonValue converts a typed value to maps, slices, strings, float64s, bools
+// and nils, the way it is seen in JSON output.
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenField
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type queryToken struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+}
+
+func (t queryToken) String() string {
+	if t.kind == tokenEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func lexQuery(source string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(source)
+	isIdent := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			text := string(runes[i : j+1])
+			value, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", text)
+			}
+			tokens = append(tokens, queryToken{kind: tokenString, text: text, value: value})
+			i = j + 1
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E') {
+				j++
+			}
+			text := string(runes[i:j])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %s", text)
+			}
+			tokens = append(tokens, queryToken{kind: tokenNumber, text: text, value: value})
+			i = j
+
+		case r == '.' && i+1 < len(runes) && isIdent(runes[i+1]) && !unicode.IsDigit(runes[i+1]):
+			j := i + 1
+			for j < len(runes) && isIdent(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{kind: tokenField, text: string(runes[i:j]), value: string(runes[i+1 : j])})
+			i = j
+
+		case isIdent(r):
+			j := i
+			for j < len(runes) && isIdent(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, queryToken{kind: tokenIdent, text: string(runes[i:j])})
+			i = j
+
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=":
+					text = two
+				}
+			}
+			if !strings.Contains(".|,;()[]{}:?<>=!-", string(r)) || text == "=" || text == "!" {
+				return nil, fmt.Errorf("unexpected character %q", text)
+			}
+			tokens = append(tokens, queryToken{kind: tokenPunct, text: text})
+			i += len([]rune(text))
+		}
+	}
+	return append(tokens, queryToken{kind: tokenEOF}), nil
+}
+
+// Parser
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) accept(text string) bool {
+	if t := p.peek(); (t.kind == tokenPunct || t.kind == tokenIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %q, found %s", text, p.peek())
+	}
+	return nil
+}
+
+func (p *queryParser) parsePipe() (queryNode, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipeNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseComma() (queryNode, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(",") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = commaNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseCompare() (queryNode, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return compareNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parsePostfix() (queryNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch t := p.peek(); {
+		case t.kind == tokenField:
+			p.next()
+			node = pipeNode{node, fieldNode{name: t.value.(string)}}
+		case t.kind == tokenPunct && t.text == "." && p.tokens[p.pos+1].kind == tokenString:
+			p.next()
+			node = pipeNode{node, fieldNode{name: p.next().value.(string)}}
+		case t.kind == tokenPunct && t.text == ".":
+			if p.tokens[p.pos+1].kind != tokenPunct || p.tokens[p.pos+1].text != "[" {
+				return node, nil
+			}
+			p.next()
+		case t.kind == tokenPunct && t.text == "[":
+			p.next()
+			index, err := p.parseIndex()
+			if err != nil {
+				return nil, err
+			}
+			node = pipeNode{node, index}
+		case t.kind == tokenPunct && t.text == "?":
+			p.next()
+			node = tryNode{node}
+		default:
+			return node, nil
+		}
+	}
+}
+
+// parseIndex parses what follows "[": "]", "n]", "n:m]" or "expr]".
+func (p *queryParser) parseIndex() (queryNode, error) {
+	if p.accept("]") {
+		return iterateNode{}, nil
+	}
+
+	var from, to queryNode
+	var err error
+	if !p.accept(":") {
+		if from, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+		if !p.accept(":") {
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return indexNode{index: from}, nil
+		}
+	}
+	if !p.accept("]") {
+		if to, err = p.parsePipe(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	}
+	return sliceNode{from: from, to: to}, nil
+}
+
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenField:
+		return fieldNode{name: t.value.(string)}, nil
+
+	case tokenString, tokenNumber:
+		return literalNode{t.value}, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return literalNode{true}, nil
+		case "false":
+			return literalNode{false}, nil
+		case "null":
+			return literalNode{nil}, nil
+		}
+
+		var args []queryNode
+		if p.accept("(") {
+			for {
+				arg, err := p.parsePipe()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				if p.accept(")") {
+					break
+				}
+				if err := p.expect(";"); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return newFuncNode(t.text, args)
+
+	case tokenPunct:
+		switch t.text {
+		case ".":
+			if next := p.peek(); next.kind == tokenString {
+				p.next()
+				return fieldNode{name: next.value.(string)}, nil
+			}
+			return identityNode{}, nil
+
+		case "-":
+			if number := p.next(); number.kind == tokenNumber {
+				return literalNode{-number.value.(float64)}, nil
+			}
+			return nil, fmt.Errorf("expected a number after \"-\"")
+
+		case "(":
+			node, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+
+		case "[":
+			if p.accept("]") {
+				return arrayNode{}, nil
+			}
+			node, err := p.parsePipe()
+			if err != nil {
+				return nil, err
+			}
+			return arrayNode{node}, p.expect("]")
+
+		case "{":
+			return p.parseObject()
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s", t)
+}
+
+func (p *queryParser) parseObject() (queryNode, error) {
+	var object objectNode
+	if p.accept("}") {
+		return object, nil
+	}
+
+	for {
+		var key string
+		switch t := p.next(); t.kind {
+		case tokenIdent:
+			key = t.text
+		case tokenString:
+			key = t.value.(string)
+		case tokenField:
+			key = t.value.(string)
+		default:
+			return nil, fmt.Errorf("expected object key, found %s", t)
+		}
+
+		var value queryNode = fieldNode{name: key}
+		if p.accept(":") {
+			var err error
+			if value, err = p.parseOr(); err != nil {
+				return nil, err
+			}
+		}
+		object.keys = append(object.keys, key)
+		object.values = append(object.values, value)
+
+		if p.accept("}") {
+			return object, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Evaluation
+
+type queryNode interface {
+	eval(input interface{}) ([]interface{}, error)
+}
+
+type identityNode struct{}
+
+func (identityNode) eval(input interface{}) ([]interface{}, error) {
+	return []interface{}{input}, nil
+}
+
+type literalNode struct{ value interface{} }
+
+func (n literalNode) eval(interface{}) ([]interface{}, error) {
+	return []interface{}{n.value}, nil
+}
+
+type pipeNode struct{ left, right queryNode }
+
+func (n pipeNode) eval(input interface{}) ([]interface{}, error) {
+	lefts, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	var results []interface{}
+	for _, left := range lefts {
+		rights, err := n.right.eval(left)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, rights...)
+	}
+	return results, nil
+}
+
+type commaNode struct{ left, right queryNode }
+
+func (n commaNode) eval(input interface{}) ([]interface{}, error) {
+	lefts, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return append(lefts, rights...), nil
+}
+
+type tryNode struct{ node queryNode }
+
+func (n tryNode) eval(input interface{}) ([]interface{}, error) {
+	results, err := n.node.eval(input)
+	if err != nil {
+		return nil, nil
+	}
+	return results, nil
+}
+
+type fieldNode struct{ name string }
+
+func (n fieldNode) eval(input interface{}) ([]interface{}, error) {
+	switch v := input.(type) {
+	case nil:
+		return []interface{}{nil}, nil
+	case map[string]interface{}:
+		return []interface{}{v[n.name]}, nil
+	}
+	return nil, fmt.Errorf("cannot index %s with %q", typeName(input), n.name)
+}
+
+type iterateNode struct{}
+
+func (iterateNode) eval(input interface{}) ([]interface{}, error) {
+	switch v := input.(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		var results []interface{}
+		for _, key := range sortedKeys(v) {
+			results = append(results, v[key])
+		}
+		return results, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %s", typeName(input))
+}
+
+type indexNode struct{ index queryNode }
+
+func (n indexNode) eval(input interface{}) ([]interface{}, error) {
+	indexes, err := n.index.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []interface{}
+	for _, index := range indexes {
+		switch i := index.(type) {
+		case string:
+			values, err := fieldNode{name: i}.eval(input)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, values...)
+		case float64:
+			switch v := input.(type) {
+			case nil:
+				results = append(results, nil)
+			case []interface{}:
+				pos := int(i)
+				if pos < 0 {
+					pos += len(v)
+				}
+				if pos < 0 || pos >= len(v) {
+					results = append(results, nil)
+				} else {
+					results = append(results, v[pos])
+				}
+			default:
+				return nil, fmt.Errorf("cannot index %s with a number", typeName(input))
+			}
+		default:
+			return nil, fmt.Errorf("cannot index %s with %s", typeName(input), typeName(index))
+		}
+	}
+	return results, nil
+}
+
+type sliceNode struct{ from, to queryNode }
+
+func (n sliceNode) eval(input interface{}) ([]interface{}, error) {
+	var length int
+	switch v := input.(type) {
+	case nil:
+		return []interface{}{nil}, nil
+	case []interface{}:
+		length = len(v)
+	case string:
+		length = len([]rune(v))
+	default:
+		return nil, fmt.Errorf("cannot slice %s", typeName(input))
+	}
+
+	bound := func(node queryNode, dflt int) (int, error) {
+		if node == nil {
+			return dflt, nil
+		}
+		values, err := node.eval(input)
+		if err != nil {
+			return 0, err
+		}
+		if len(values) != 1 {
+			return 0, fmt.Errorf("slice bounds must be single numbers")
+		}
+		f, ok := values[0].(float64)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be numbers, not %s", typeName(values[0]))
+		}
+		i := int(f)
+		if i < 0 {
+			i += length
+		}
+		return int(math.Max(0, math.Min(float64(i), float64(length)))), nil
+	}
+
+	from, err := bound(n.from, 0)
+	if err != nil {
+		return nil, err
+	}
+	to, err := bound(n.to, length)
+	if err != nil {
+		return nil, err
+	}
+	if to < from {
+		to = from
+	}
+
+	if s, ok := input.(string); ok {
+		return []interface{}{string([]rune(s)[from:to])}, nil
+	}
+	return []interface{}{append([]interface{}{}, input.([]interface{})[from:to]...)}, nil
+}
+
+type arrayNode struct{ node queryNode }
+
+func (n arrayNode) eval(input interface{}) ([]interface{}, error) {
+	if n.node == nil {
+		return []interface{}{[]interface{}{}}, nil
+	}
+	values, err := n.node.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = []interface{}{}
+	}
+	return []interface{}{values}, nil
+}
+
+type objectNode struct {
+	keys   []string
+	values []queryNode
+}
+
+func (n objectNode) eval(input interface{}) ([]interface{}, error) {
+	objects := []map[string]interface{}{{}}
+	for i, key := range n.keys {
+		values, err := n.values[i].eval(input)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []map[string]interface{}
+		for _, object := range objects {
+			for _, value := range values {
+				copied := make(map[string]interface{}, len(object)+1)
+				for k, v := range object {
+					copied[k] = v
+				}
+				copied[key] = value
+				next = append(next, copied)
+			}
+		}
+		objects = next
+	}
+
+	results := make([]interface{}, 0, len(objects))
+	for _, object := range objects {
+		results = append(results, object)
+	}
+	return results, nil
+}
+
+type compareNode struct {
+	op          string
+	left, right queryNode
+}
+
+func (n compareNode) eval(input interface{}) ([]interface{}, error) {
+	lefts, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	rights, err := n.right.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []interface{}
+	for _, right := range rights {
+		for _, left := range lefts {
+			c := compareValues(left, right)
+			var result bool
+			switch n.op {
+			case "==":
+				result = c == 0
+			case "!=":
+				result = c != 0
+			case "<":
+				result = c < 0
+			case "<=":
+				result = c <= 0
+			case ">":
+				result = c > 0
+			case ">=":
+				result = c >= 0
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+type logicNode struct {
+	and         bool
+	left, right queryNode
+}
+
+func (n logicNode) eval(input interface{}) ([]interface{}, error) {
+	lefts, err := n.left.eval(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []interface{}
+	for _, left := range lefts {
+		if truthy(left) != n.and {
+			results = append(results, truthy(left))
+			continue
+		}
+		rights, err := n.right.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, right := range rights {
+			results = append(results, truthy(right))
+		}
+	}
+	return results, nil
+}
+
+type funcNode struct {
+	name string
+	args []queryNode
+}
+
+// queryFuncs maps each function to its number of arguments.
+var queryFuncs = map[string]int{
+	"length": 0, "keys": 0, "values": 0, "map": 1, "select": 1, "has": 1,
+	"contains": 1, "test": 1, "startswith": 1, "endswith": 1,
+	"ascii_downcase": 0, "ascii_upcase": 0, "sort": 0, "sort_by": 1,
+	"unique": 0, "first": 0, "last": 0, "join": 1, "not": 0,
+	"tostring": 0, "type": 0, "add": 0, "empty": 0,
+}
+
+func newFuncNode(name string, args []queryNode) (queryNode, error) {
+	arity, ok := queryFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s takes %d argument(s), not %d", name, arity, len(args))
+	}
+	return funcNode{name: name, args: args}, nil
+}
+
+func (n funcNode) eval(input interface{}) ([]interface{}, error) {
+	// Functions whose argument is evaluated per element or per input.
+	switch n.name {
+	case "empty":
+		return nil, nil
+
+	case "select":
+		conditions, err := n.args[0].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		var results []interface{}
+		for _, condition := range conditions {
+			if truthy(condition) {
+				results = append(results, input)
+			}
+		}
+		return results, nil
+
+	case "map":
+		values, err := iterateNode{}.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		mapped := []interface{}{}
+		for _, value := range values {
+			results, err := n.args[0].eval(value)
+			if err != nil {
+				return nil, err
+			}
+			mapped = append(mapped, results...)
+		}
+		return []interface{}{mapped}, nil
+
+	case "sort_by":
+		list, ok := input.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot sort %s", typeName(input))
+		}
+		keys := make([][]interface{}, len(list))
+		for i, value := range list {
+			key, err := n.args[0].eval(value)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = key
+		}
+		index := make([]int, len(list))
+		for i := range index {
+			index[i] = i
+		}
+		sort.SliceStable(index, func(i, j int) bool {
+			return compareValues(keys[index[i]], keys[index[j]]) < 0
+		})
+		sorted := make([]interface{}, len(list))
+		for i, j := range index {
+			sorted[i] = list[j]
+		}
+		return []interface{}{sorted}, nil
+	}
+
+	if len(n.args) == 1 {
+		args, err := n.args[0].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		var results []interface{}
+		for _, arg := range args {
+			result, err := callQueryFunc(n.name, input, arg)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		return results, nil
+	}
+
+	result, err := callQueryFunc(n.name, input, nil)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{result}, nil
+}
+
+func callQueryFunc(name string, input interface{}, arg interface{}) (interface{}, error) {
+	switch name {
+	case "length":
+		switch v := input.(type) {
+		case nil:
+			return float64(0), nil
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case float64:
+			return math.Abs(v), nil
+		}
+
+	case "keys":
+		switch v := input.(type) {
+		case map[string]interface{}:
+			keys := []interface{}{}
+			for _, key := range sortedKeys(v) {
+				keys = append(keys, key)
+			}
+			return keys, nil
+		case []interface{}:
+			keys := []interface{}{}
+			for i := range v {
+				keys = append(keys, float64(i))
+			}
+			return keys, nil
+		}
+
+	case "values":
+		values, err := iterateNode{}.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = []interface{}{}
+		}
+		return values, nil
+
+	case "has":
+		switch v := input.(type) {
+		case map[string]interface{}:
+			key, ok := arg.(string)
+			if !ok {
+				return nil, fmt.Errorf("has: object keys are strings, not %s", typeName(arg))
+			}
+			_, found := v[key]
+			return found, nil
+		case []interface{}:
+			i, ok := arg.(float64)
+			if !ok {
+				return nil, fmt.Errorf("has: array indexes are numbers, not %s", typeName(arg))
+			}
+			return i >= 0 && int(i) < len(v), nil
+		}
+
+	case "contains":
+		return containsValue(input, arg), nil
+
+	case "test", "startswith", "endswith", "join":
+		s, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: argument must be a string, not %s", name, typeName(arg))
+		}
+		if name == "join" {
+			list, ok := input.([]interface{})
+			if !ok {
+				break
+			}
+			var parts []string
+			for _, value := range list {
+				if value != nil {
+					parts = append(parts, scalarString(value))
+				} else {
+					parts = append(parts, "")
+				}
+			}
+			return strings.Join(parts, s), nil
+		}
+
+		text, ok := input.(string)
+		if !ok {
+			break
+		}
+		switch name {
+		case "test":
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("test: %w", err)
+			}
+			return re.MatchString(text), nil
+		case "startswith":
+			return strings.HasPrefix(text, s), nil
+		default:
+			return strings.HasSuffix(text, s), nil
+		}
+
+	case "ascii_downcase", "ascii_upcase":
+		text, ok := input.(string)
+		if !ok {
+			break
+		}
+		if name == "ascii_downcase" {
+			return strings.ToLower(text), nil
+		}
+		return strings.ToUpper(text), nil
+
+	case "sort", "unique":
+		list, ok := input.([]interface{})
+		if !ok {
+			break
+		}
+		sorted := append([]interface{}{}, list...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return compareValues(sorted[i], sorted[j]) < 0
+		})
+		if name == "unique" {
+			unique := []interface{}{}
+			for i, value := range sorted {
+				if i == 0 || compareValues(sorted[i-1], value) != 0 {
+					unique = append(unique, value)
+				}
+			}
+			sorted = unique
+		}
+		return sorted, nil
+
+	case "first", "last":
+		list, ok := input.([]interface{})
+		if !ok {
+			break
+		}
+		if len(list) == 0 {
+			return nil, nil
+		}
+		if name == "first" {
+			return list[0], nil
+		}
+		return list[len(list)-1], nil
+
+	case "not":
+		return !truthy(input), nil
+
+	case "tostring":
+		if s, ok := input.(string); ok {
+			return s, nil
+		}
+		return jsonString(input), nil
+
+	case "type":
+		return typeName(input), nil
+
+	case "add":
+		list, ok := input.([]interface{})
+		if !ok {
+			break
+		}
+		return addValues(list)
+	}
+
+	return nil, fmt.Errorf("%s cannot be applied to %s", name, typeName(input))
+}
+
+func addValues(list []interface{}) (interface{}, error) {
+	var sum interface{}
+	for _, value := range list {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case float64:
+			if s, ok := sum.(float64); ok {
+				sum = s + v
+				continue
+			}
+		case string:
+			if s, ok := sum.(string); ok {
+				sum = s + v
+				continue
+			}
+		case []interface{}:
+			if s, ok := sum.([]interface{}); ok {
+				sum = append(append([]interface{}{}, s...), v...)
+				continue
+			}
+		case map[string]interface{}:
+			if s, ok := sum.(map[string]interface{}); ok {
+				merged := make(map[string]interface{}, len(s)+len(v))
+				for k, x := range s {
+					merged[k] = x
+				}
+				for k, x := range v {
+					merged[k] = x
+				}
+				sum = merged
+				continue
+			}
+		}
+		if sum != nil {
+			return nil, fmt.Errorf("add: cannot add %s to %s", typeName(value), typeName(sum))
+		}
+		sum = value
+	}
+	return sum, nil
+}
+
+func containsValue(a interface{}, b interface{}) bool {
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		return ok && strings.Contains(av, bv)
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, want := range bv {
+			found := false
+			for _, have := range av {
+				if containsValue(have, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, want := range bv {
+			have, found := av[key]
+			if !found || !containsValue(have, want) {
+				return false
+			}
+		}
+		return true
+	}
+	return compareValues(a, b) == 0
+}
+
+// compareValues orders values the way jq does: null < false < true < numbers
+// < strings < arrays < objects.
+func compareValues(a interface{}, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch av := a.(type) {
+	case bool:
+		bv := b.(bool)
+		switch {
+		case av == bv:
+			return 0
+		case !av:
+			return -1
+		}
+		return 1
+	case float64:
+		bv := b.(float64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(av, b.(string))
+	case []interface{}:
+		bv := b.([]interface{})
+		for i := 0; i < len(av) && i < len(bv); i++ {
+			if c := compareValues(av[i], bv[i]); c != 0 {
+				return c
+			}
+		}
+		return len(av) - len(bv)
+	case map[string]interface{}:
+		if reflect.DeepEqual(av, b) {
+			return 0
+		}
+		return strings.Compare(jsonString(av), jsonString(b))
+	}
+	return 0
+}
+
+func typeRank(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func truthy(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// scalarString formats a query result for a table cell or a join.
+func scalarString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return jsonString(v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const queryInput = `[
+	{"Name": "Editor", "Id": "r1", "Abilities": ["Read", "Write"], "Level": 2, "Active": true},
+	{"Name": "Viewer", "Id": "r2", "Abilities": ["Read"], "Level": 1, "Active": false},
+	{"Name": "Admin", "Id": "r3", "Abilities": [], "Level": 4, "Active": true, "Note": null}
+]`
+
+func runQuery(t *testing.T, source string) ([]interface{}, error) {
+	t.Helper()
+	var input interface{}
+	if err := json.Unmarshal([]byte(queryInput), &input); err != nil {
+		t.Fatal(err)
+	}
+	q, err := ParseQuery(source)
+	if err != nil {
+		return nil, err
+	}
+	return q.Run(input)
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string // JSON of the results
+	}{
+		{"identity length", ". | length", `[3]`},
+		{"index", ".[0].Name", `["Editor"]`},
+		{"negative index", ".[-1].Id", `["r3"]`},
+		{"slice", ".[1:3] | map(.Id)", `[["r2","r3"]]`},
+		{"iterate", ".[].Name", `["Editor","Viewer","Admin"]`},
+		{"quoted key", `.[0]."Id"`, `["r1"]`},
+		{"missing field", ".[0].Missing", `[null]`},
+		{"optional", ".[0].Name.Deeper?", `[]`},
+		{"pipe", ".[] | .Abilities | length", `[2,1,0]`},
+		{"comma", ".[0] | .Name, .Id", `["Editor","r1"]`},
+		{"select", `.[] | select(.Level > 1) | .Name`, `["Editor","Admin"]`},
+		{"select and", `.[] | select(.Active and .Level < 3) | .Name`, `["Editor"]`},
+		{"select or", `.[] | select(.Name == "Viewer" or .Level >= 4) | .Id`, `["r2","r3"]`},
+		{"select not", `.[] | select(.Active | not) | .Name`, `["Viewer"]`},
+		{"select negative", `.[] | select(.Level > -1) | .Id`, `["r1","r2","r3"]`},
+		{"map", "map(.Level)", `[[2,1,4]]`},
+		{"array construction", "[.[] | .Id]", `[["r1","r2","r3"]]`},
+		{"object construction", `.[0] | {Name, id: .Id, "n": (.Abilities | length)}`, `[{"Name":"Editor","id":"r1","n":2}]`},
+		{"keys", ".[1] | keys", `[["Abilities","Active","Id","Level","Name"]]`},
+		{"has", `.[2] | has("Note")`, `[true]`},
+		{"contains", `.[0].Abilities | contains(["Write"])`, `[true]`},
+		{"test", `.[] | select(.Name | test("(?i)^ad")) | .Id`, `["r3"]`},
+		{"startswith", `.[] | select(.Name | startswith("Ed")) | .Id`, `["r1"]`},
+		{"endswith", `.[] | select(.Name | endswith("or")) | .Id`, `["r1"]`},
+		{"case", ".[0].Name | ascii_downcase, ascii_upcase", `["editor","EDITOR"]`},
+		{"sort", "map(.Name) | sort", `[["Admin","Editor","Viewer"]]`},
+		{"sort_by", "sort_by(.Level) | map(.Id)", `[["r2","r1","r3"]]`},
+		{"unique", "map(.Abilities[]) | unique", `[["Read","Write"]]`},
+		{"first last", "map(.Id) | first, last", `["r1","r3"]`},
+		{"join", `map(.Name) | join(", ")`, `["Editor, Viewer, Admin"]`},
+		{"tostring", ".[0].Level | tostring", `["2"]`},
+		{"type", ".[0] | .Name, .Level, .Active, .Abilities | type", `["string","number","boolean","array"]`},
+		{"add", "map(.Level) | add", `[7]`},
+		{"empty", ".[] | empty", `[]`},
+		{"values", `.[0] | {a: 1, b: "x"} | values`, `[[1,"x"]]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := runQuery(t, tt.query)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.query, err)
+			}
+			if results == nil {
+				results = []interface{}{}
+			}
+			got, err := json.Marshal(results)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("%s = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string // part of the error message
+	}{
+		{"recursive descent", "..", "invalid query"},
+		{"reverse", "reverse", "unknown function reverse"},
+		{"addition", ".[0].Level + 1", "unexpected character"},
+		{"multiplication", ".[0].Level * 2", "unexpected character"},
+		{"assignment", ".Name = 1", "unexpected character"},
+		{"unterminated string", `"abc`, "unterminated string"},
+		{"arity", "map", "map takes 1 argument(s), not 0"},
+		{"unclosed bracket", ".[0", "expected"},
+		{"trailing input", ".Name )", "unexpected"},
+		{"index string with field", ".[0].Name.Deeper", "cannot index string"},
+		{"iterate number", ".[0].Level[]", "cannot iterate over number"},
+		{"bad regex", `.[0].Name | test("(")`, "test:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := runQuery(t, tt.query)
+			if err == nil {
+				t.Fatalf("%s: expected an error", tt.query)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: error %q does not contain %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,539 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReviewManagerAttribute is the group attribute naming the approval manager
+// who reviews the group's members, unless another attribute is given.
+const ReviewManagerAttribute = "ApprovalManager"
+
+// ReviewUnassigned is the reviewer of groups without an approval manager.
+const ReviewUnassigned = "unassigned"
+
+// ReviewKeyFile holds the key review reports are signed with. It is created,
+// with a random key, the first time a report is signed.
+const ReviewKeyFile = "~/.c3po/review.key"
+
+// Reviewer decisions, as written in the worksheet's Decision column.
+const (
+	ReviewKeep   = "keep"
+	ReviewRevoke = "revoke"
+)
+
+// Review outcome statuses reported in ReviewOutcome.Status. Under --dry-run
+// nothing is revoked, and ReviewWouldRevoke is reported instead.
+const (
+	ReviewKept        = "kept"
+	ReviewRevoked     = "revoked"
+	ReviewWouldRevoke = "would revoke"
+	ReviewSkipped     = "skipped"
+	ReviewFailed      = "failed"
+)
+
+// ReviewItem is one group membership to recertify: a row of a worksheet.
+// DataClassification is the highest of the abilities' classifications and
+// LastUpdate the group's. Decision and Comment are filled in by the reviewer.
+type ReviewItem struct {
+	Reviewer           string   `json:"Reviewer"`
+	Group              string   `json:"Group"`
+	GroupId            string   `json:"GroupId"`
+	HubID              string   `json:"HubID"`
+	UserId             string   `json:"UserId"`
+	CommonName         string   `json:"CommonName"`
+	Email              string   `json:"Email"`
+	IsActive           bool     `json:"IsActive"`
+	Roles              []string `json:"Roles"`
+	Abilities          []string `json:"Abilities"`
+	DataClassification int      `json:"DataClassification"`
+	LastUpdate         string   `json:"LastUpdate"`
+	Decision           string   `json:"Decision"`
+	Comment            string   `json:"Comment"`
+}
+
+// ReviewWorksheet is every membership one approval manager has to review.
+type ReviewWorksheet struct {
+	Reviewer string
+	Items    []ReviewItem
+}
+
+// reviewColumns is the header of a CSV worksheet. Reading accepts the
+// columns in any order, since spreadsheets tend to move them around.
+var reviewColumns = []string{"Reviewer", "Group", "GroupId", "HubID", "UserId", "CommonName", "Email", "IsActive", "Roles", "Abilities", "DataClassification", "LastUpdate", "Decision", "Comment"}
+
+// BuildReview lists every group membership in the state, one worksheet per
+// approval manager, as named by the group attribute managerAttribute. Groups
+// without one go to the ReviewUnassigned worksheet. Worksheets are sorted by
+// reviewer and their items by group and HubID.
+func BuildReview(state *State, managerAttribute string) []ReviewWorksheet {
+	worksheets := make(map[string]*ReviewWorksheet)
+	var reviewers []string
+
+	for _, group := range state.Groups {
+		if len(group.Members) == 0 {
+			continue
+		}
+
+		reviewer := groupReviewer(group, managerAttribute)
+
+		var roles, abilities []string
+		classification := 0
+		for _, binding := range group.Roles {
+			role, ok := state.Role(binding.RoleId)
+			if !ok {
+				continue
+			}
+			roles = append(roles, role.Name)
+			for _, ability := range state.RoleAbilities(role) {
+				if !containsFold(abilities, ability.Name) {
+					abilities = append(abilities, ability.Name)
+				}
+				if ability.DataClassification > classification {
+					classification = ability.DataClassification
+				}
+			}
+		}
+		sortFold(roles)
+		sortFold(abilities)
+
+		key := strings.ToLower(reviewer)
+		worksheet, ok := worksheets[key]
+		if !ok {
+			worksheet = &ReviewWorksheet{Reviewer: reviewer}
+			worksheets[key] = worksheet
+			reviewers = append(reviewers, key)
+		}
+
+		for _, user := range group.Members {
+			worksheet.Items = append(worksheet.Items, ReviewItem{
+				Reviewer:           reviewer,
+				Group:              group.Group.Name,
+				GroupId:            group.Group.Id,
+				HubID:              user.IdAtSourceSystem,
+				UserId:             user.Id,
+				CommonName:         user.CommonName,
+				Email:              user.Email,
+				IsActive:           user.IsActive,
+				Roles:              roles,
+				Abilities:          abilities,
+				DataClassification: classification,
+				LastUpdate:         group.Group.LastUpdate,
+			})
+		}
+	}
+
+	sort.Strings(reviewers)
+	result := make([]ReviewWorksheet, 0, len(reviewers))
+	for _, key := range reviewers {
+		result = append(result, *worksheets[key])
+	}
+	return result
+}
+
+// groupReviewer returns the approval manager of a group, as named by the
+// group attribute managerAttribute, or ReviewUnassigned.
+func groupReviewer(group GroupState, managerAttribute string) string {
+	for _, attr := range group.Attributes {
+		if attr.UsageType == UsageTypeGroup && strings.EqualFold(attr.AttributeName, managerAttribute) && strings.TrimSpace(attr.AttributeValue) != "" {
+			return strings.TrimSpace(attr.AttributeValue)
+		}
+	}
+	return ReviewUnassigned
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ReviewWorksheetName returns the file name of a reviewer's worksheet, with
+// the extension of the format ("csv" or "json").
+func ReviewWorksheetName(reviewer string, format string) string {
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(reviewer, "_"), "_")
+	if name == "" {
+		name = ReviewUnassigned
+	}
+	return "review-" + name + "." + format
+}
+
+// ReviewWorksheetNames returns the file names of the worksheets, in order.
+// Reviewers whose names reduce to the same file name ("John Smith" and
+// "John_Smith"), ignoring case, get a numbered suffix after the first.
+func ReviewWorksheetNames(worksheets []ReviewWorksheet, format string) []string {
+	names := make([]string, len(worksheets))
+	used := make(map[string]bool)
+	for i, worksheet := range worksheets {
+		name := ReviewWorksheetName(worksheet.Reviewer, format)
+		base := strings.TrimSuffix(name, "."+format)
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s-%d.%s", base, n, format)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// WriteReviewWorksheet writes the worksheet to path, as JSON when path ends in
+// .json and as CSV otherwise. An existing file is never overwritten.
+func WriteReviewWorksheet(path string, worksheet ReviewWorksheet) error {
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := json.MarshalIndent(worksheet.Items, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	} else {
+		w := csv.NewWriter(&buf)
+		if err := w.Write(reviewColumns); err != nil {
+			return err
+		}
+		for _, item := range worksheet.Items {
+			record := []string{
+				item.Reviewer, item.Group, item.GroupId, item.HubID, item.UserId, item.CommonName, item.Email,
+				strconv.FormatBool(item.IsActive), strings.Join(item.Roles, "; "), strings.Join(item.Abilities, "; "),
+				strconv.Itoa(item.DataClassification), item.LastUpdate, item.Decision, item.Comment,
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadReviewWorksheet parses a completed worksheet written by
+// WriteReviewWorksheet and read from path, JSON or CSV depending on its
+// extension. Decisions are lowercased; they are checked by PlanReview.
+func ReadReviewWorksheet(data []byte, path string) ([]ReviewItem, error) {
+	var items []ReviewItem
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	} else {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		header, err := r.Read()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		index := make(map[string]int)
+		for i, name := range header {
+			index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+		}
+		for _, name := range []string{"GroupId", "UserId", "Decision"} {
+			if _, ok := index[strings.ToLower(name)]; !ok {
+				return nil, fmt.Errorf("%s has no %s column", path, name)
+			}
+		}
+		field := func(record []string, name string) string {
+			if i, ok := index[strings.ToLower(name)]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			active, _ := strconv.ParseBool(field(record, "IsActive"))
+			classification, _ := strconv.Atoi(field(record, "DataClassification"))
+			items = append(items, ReviewItem{
+				Reviewer:           field(record, "Reviewer"),
+				Group:              field(record, "Group"),
+				GroupId:            field(record, "GroupId"),
+				HubID:              field(record, "HubID"),
+				UserId:             field(record, "UserId"),
+				CommonName:         field(record, "CommonName"),
+				Email:              field(record, "Email"),
+				IsActive:           active,
+				Roles:              splitList(field(record, "Roles")),
+				Abilities:          splitList(field(record, "Abilities")),
+				DataClassification: classification,
+				LastUpdate:         field(record, "LastUpdate"),
+				Decision:           field(record, "Decision"),
+				Comment:            field(record, "Comment"),
+			})
+		}
+	}
+
+	for i := range items {
+		items[i].Decision = strings.ToLower(strings.TrimSpace(items[i].Decision))
+	}
+	return items, nil
+}
+
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// ReviewOutcome is what became of one reviewed membership.
+type ReviewOutcome struct {
+	ReviewItem
+	Status string `json:"Status"`
+	Reason string `json:"Reason,omitempty"`
+}
+
+// PlanReview checks a completed worksheet against the current state. Every
+// row needs a keep or revoke decision, and a membership may not be decided
+// twice. Rows must all name the same reviewer, and every group that still
+// exists must still be assigned to that reviewer (by the group attribute
+// managerAttribute, as in BuildReview), so that a worksheet cannot revoke
+// memberships of groups its reviewer does not approve. Otherwise an error
+// lists the offending rows, numbered as in a spreadsheet (the header is row
+// 1). Memberships that no longer exist are skipped, kept ones are marked
+// kept, and revoked ones are left with an empty Status for ApplyReview.
+func PlanReview(state *State, items []ReviewItem, managerAttribute string) ([]ReviewOutcome, error) {
+	groups := make(map[string]GroupState)
+	for _, group := range state.Groups {
+		groups[group.Group.Id] = group
+	}
+
+	reviewer := ""
+	if len(items) > 0 {
+		reviewer = items[0].Reviewer
+	}
+
+	var problems []string
+	seen := make(map[string]int)
+	for i, item := range items {
+		row := i + 2
+		switch {
+		case item.GroupId == "" || item.UserId == "":
+			problems = append(problems, fmt.Sprintf("row %d: GroupId and UserId are required", row))
+		case item.Reviewer == "" || !strings.EqualFold(item.Reviewer, reviewer):
+			problems = append(problems, fmt.Sprintf("row %d (%s in %s): reviewer %q is not the worksheet's reviewer %q", row, item.HubID, item.Group, item.Reviewer, reviewer))
+		case item.Decision == "":
+			problems = append(problems, fmt.Sprintf("row %d (%s in %s): no decision", row, item.HubID, item.Group))
+		case item.Decision != ReviewKeep && item.Decision != ReviewRevoke:
+			problems = append(problems, fmt.Sprintf("row %d (%s in %s): decision %q is neither %s nor %s", row, item.HubID, item.Group, item.Decision, ReviewKeep, ReviewRevoke))
+		}
+
+		if group, ok := groups[item.GroupId]; ok && reviewer != "" && strings.EqualFold(item.Reviewer, reviewer) {
+			if owner := groupReviewer(group, managerAttribute); !strings.EqualFold(owner, reviewer) {
+				problems = append(problems, fmt.Sprintf("row %d (%s in %s): the group is reviewed by %q, not %q", row, item.HubID, group.Group.Name, owner, reviewer))
+			}
+		}
+
+		key := item.GroupId + "/" + item.UserId
+		if first, ok := seen[key]; ok {
+			problems = append(problems, fmt.Sprintf("row %d (%s in %s): already decided on row %d", row, item.HubID, item.Group, first))
+		} else {
+			seen[key] = row
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("the worksheet cannot be applied:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	outcomes := make([]ReviewOutcome, 0, len(items))
+	for _, item := range items {
+		outcome := ReviewOutcome{ReviewItem: item}
+
+		group, ok := groups[item.GroupId]
+		member := false
+		if ok {
+			for _, user := range group.Members {
+				if user.Id == item.UserId {
+					member = true
+					break
+				}
+			}
+		}
+
+		switch {
+		case !ok:
+			outcome.Status, outcome.Reason = ReviewSkipped, "group no longer exists"
+		case !member:
+			outcome.Status, outcome.Reason = ReviewSkipped, "no longer a member"
+		case item.Decision == ReviewKeep:
+			outcome.Status = ReviewKept
+		}
+		outcomes = append(outcomes, outcome)
+	}
+	return outcomes, nil
+}
+
+// ApplyReview removes every membership PlanReview left to revoke, calling
+// progress after each one, and returns the outcomes with their final status.
+func (c *Client) ApplyReview(outcomes []ReviewOutcome, progress func(ReviewOutcome)) []ReviewOutcome {
+	for i, outcome := range outcomes {
+		if outcome.Status != "" {
+			continue
+		}
+		if err := c.RemoveGroupMember(outcome.GroupId, outcome.UserId); err != nil {
+			outcome.Status, outcome.Reason = ReviewFailed, err.Error()
+		} else {
+			outcome.Status = ReviewRevoked
+			if c.dryRun {
+				outcome.Status = ReviewWouldRevoke
+			}
+		}
+		outcomes[i] = outcome
+		if progress != nil {
+			progress(outcome)
+		}
+	}
+	return outcomes
+}
+
+// ReviewReport is the record of an applied review: which worksheet (by
+// SHA-256) was applied, by whom, and the outcome of every row. Signature is
+// an HMAC-SHA256 of the report without it.
+type ReviewReport struct {
+	ApplicationId     string          `json:"ApplicationId"`
+	Environment       string          `json:"Environment"`
+	Worksheet         string          `json:"Worksheet"`
+	WorksheetChecksum string          `json:"WorksheetChecksum"`
+	AppliedBy         string          `json:"AppliedBy"`
+	Applied           time.Time       `json:"Applied"`
+	DryRun            bool            `json:"DryRun"`
+	Counts            map[string]int  `json:"Counts"`
+	Outcomes          []ReviewOutcome `json:"Outcomes"`
+	Signature         string          `json:"Signature,omitempty"`
+}
+
+// NewReviewReport describes the outcomes of applying the worksheet read from
+// path (its content is data).
+func (c *Client) NewReviewReport(path string, data []byte, outcomes []ReviewOutcome) *ReviewReport {
+	report := &ReviewReport{
+		ApplicationId:     c.c3poApplicationID,
+		Environment:       c.c3poEnvironment,
+		Worksheet:         filepath.Base(path),
+		WorksheetChecksum: checksum(data),
+		AppliedBy:         c.c3poUsername,
+		Applied:           time.Now().UTC(),
+		DryRun:            c.DryRun(),
+		Counts:            map[string]int{ReviewKept: 0, ReviewRevoked: 0, ReviewSkipped: 0, ReviewFailed: 0},
+		Outcomes:          outcomes,
+	}
+	for _, outcome := range outcomes {
+		report.Counts[outcome.Status]++
+	}
+	return report
+}
+
+// Sign sets the report's signature.
+func (r *ReviewReport) Sign(key []byte) error {
+	signature, err := r.signature(key)
+	if err != nil {
+		return err
+	}
+	r.Signature = signature
+	return nil
+}
+
+// Verify reports whether the report's signature matches its content.
+func (r *ReviewReport) Verify(key []byte) (bool, error) {
+	signature, err := r.signature(key)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal([]byte(signature), []byte(r.Signature)), nil
+}
+
+func (r *ReviewReport) signature(key []byte) (string, error) {
+	unsigned := *r
+	unsigned.Signature = ""
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// LoadReviewKey reads the signing key from path. When create is set and the
+// file does not exist, a random key is written there first; created says so.
+func LoadReviewKey(path string, create bool) (key []byte, created bool, err error) {
+	absPath, err := AbsolutePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(absPath)
+	if os.IsNotExist(err) && create {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, false, err
+		}
+		data = []byte(hex.EncodeToString(random) + "\n")
+		if err := os.MkdirAll(filepath.Dir(absPath), 0700); err != nil {
+			return nil, false, err
+		}
+		if err := os.WriteFile(absPath, data, 0600); err != nil {
+			return nil, false, err
+		}
+		created = true
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	key = bytes.TrimSpace(data)
+	if len(key) == 0 {
+		return nil, false, fmt.Errorf("signing key %s is empty", path)
+	}
+	return key, created, nil
+}
+
+// WriteReviewReport saves the report as indented JSON.
+func WriteReviewReport(path string, report *ReviewReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// ReadReviewReport loads a report written by WriteReviewReport.
+func ReadReviewReport(path string) (*ReviewReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report ReviewReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &report, nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LookupRole returns the single role named name, ignoring case and repeated spaces.
+func (c *Client) LookupRole(name string) (Role, error) {
+	matches, err := c.FindRoles(name, MatchExact, 0)
+	if err != nil {
+		return Role{}, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return Role{}, fmt.Errorf("role %q not found", name)
+	case 1:
+		return matches[0].Role, nil
+	}
+
+	var names []string
+	for _, m := range matches {
+		names = append(names, m.Role.Name)
+	}
+	return Role{}, fmt.Errorf("role %q is ambiguous: %s", name, strings.Join(names, ", "))
+}
+
+// ValidateRole checks a role before it is sent to Keystone.
+func (c *Client) ValidateRole(role Role) error {
+	if err := c.ValidateRoleName(role.Name); err != nil {
+		return err
+	}
+	if err := ValidateExpression(role.ConditionalExpression); err != nil {
+		return fmt.Errorf("role %q: %w", role.Name, err)
+	}
+	return nil
+}
+
+// ValidateRoleName reports whether name follows the role naming convention:
+// no "C3PO - " prefix (that is for groups) and no stray spaces.
+func (c *Client) ValidateRoleName(name string) error {
+	trimmed := strings.TrimSpace(name)
+	if trimmed == "" {
+		return fmt.Errorf("role name cannot be empty")
+	}
+	if trimmed != name || c.reduceSpaces(trimmed) != trimmed {
+		return fmt.Errorf("role name %q has leading, trailing or repeated spaces", name)
+	}
+	if c.removeC3POPrefixes(trimmed) != trimmed {
+		return fmt.Errorf("role name %q must not start with 'C3PO - '", name)
+	}
+	return nil
+}
+
+// RoleAbilities builds the RoleFunctionalAbilities value Keystone expects for
+// the given functional ability ids.
+func RoleAbilities(abilityIds []string) []map[string]string {
+	abilities := make([]map[string]string, 0, len(abilityIds))
+	for _, id := range abilityIds {
+		abilities = append(abilities, map[string]string{"FunctionalAbilityId": id})
+	}
+	return abilities
+}
+
+// ResolveFunctionalAbilities looks up functional abilities by exact name.
+func (c *Client) ResolveFunctionalAbilities(names []string) ([]FunctionalAbilities, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	abilities, err := c.ListFunctionalAbilities()
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved []FunctionalAbilities
+	var missing []string
+	for _, name := range names {
+		found := false
+		for _, ability := range abilities {
+			if strings.EqualFold(ability.Name, strings.TrimSpace(name)) {
+				resolved = append(resolved, ability)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("functional abilities not found: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// CreateRole creates a role in the application and returns it as stored by Keystone.
+func (c *Client) CreateRole(role Role) (Role, error) {
+	role.ApplicationId = c.c3poApplicationID
+	if err := c.ValidateRole(role); err != nil {
+		return Role{}, err
+	}
+	return c.createRole(role)
+}
+
+// createRole creates role as given, without checking the naming convention or
+// the expression (restore brings back what Keystone held).
+func (c *Client) createRole(role Role) (Role, error) {
+	role.ApplicationId = c.c3poApplicationID
+	created := role
+	err := c.keystone("POST", "adminservice/keystone/v1/application/"+c.c3poApplicationID+"/role", "", role, &created)
+	if err != nil {
+		return Role{}, err
+	}
+	return created, nil
+}
+
+// UpdateRole replaces the name, description, conditional expression and
+// functional abilities of an existing role. Callers validate the fields they
+// change (ValidateRoleName, ValidateExpression), so that a role whose stored
+// name or expression predates the conventions can still be edited.
+func (c *Client) UpdateRole(role Role) error {
+	if role.Id == "" {
+		return fmt.Errorf("role %q has no Id", role.Name)
+	}
+	role.ApplicationId = c.c3poApplicationID
+	return c.keystone("PUT", "adminservice/keystone/v1/application/"+c.c3poApplicationID+"/role/"+role.Id, "", role, nil)
+}
+
+// DeleteRole removes a role from the application.
+func (c *Client) DeleteRole(roleId string) error {
+	if roleId == "" {
+		return fmt.Errorf("role id cannot be empty")
+	}
+	return c.keystone("DELETE", "adminservice/keystone/v1/application/"+c.c3poApplicationID+"/role/"+roleId, "", nil, nil)
+}
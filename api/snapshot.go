@@ -0,0 +1,183 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotDir is where snapshots are saved unless another directory is given.
+const SnapshotDir = "~/.c3po/snapshots"
+
+// snapshotTimeFormat is used in snapshot file names; it sorts chronologically.
+const snapshotTimeFormat = "20060102T150405Z"
+
+// Snapshot is the state of the application at a point in time.
+type Snapshot struct {
+	Taken         time.Time `json:"Taken"`
+	ApplicationId string    `json:"ApplicationId"`
+	State         *State    `json:"State"`
+}
+
+// SnapshotInfo locates a saved snapshot without loading it.
+type SnapshotInfo struct {
+	Path          string
+	ApplicationId string
+	Taken         time.Time
+}
+
+// TakeSnapshot fetches the current state of the application.
+func (c *Client) TakeSnapshot() (*Snapshot, error) {
+	state, err := c.FetchState()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Taken: time.Now().UTC(), ApplicationId: state.ApplicationId, State: state}, nil
+}
+
+// SaveSnapshot writes the snapshot as gzip-compressed JSON in dir and returns its path.
+func SaveSnapshot(dir string, snapshot *Snapshot) (string, error) {
+	absDir, err := AbsolutePath(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(absDir, 0700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(absDir, snapshot.ApplicationId+"-"+snapshot.Taken.UTC().Format(snapshotTimeFormat)+".json.gz")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	encoder := json.NewEncoder(zw)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(snapshot); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// LoadSnapshot reads a snapshot written by SaveSnapshot.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", path, err)
+	}
+	if snapshot.State == nil {
+		return nil, fmt.Errorf("snapshot %s has no state", path)
+	}
+	return &snapshot, nil
+}
+
+// ListSnapshots returns the snapshots saved in dir, oldest first. When
+// applicationId is not empty only that application's snapshots are listed.
+func ListSnapshots(dir string, applicationId string) ([]SnapshotInfo, error) {
+	absDir, err := AbsolutePath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(absDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snapshots []SnapshotInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json.gz") {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, ".json.gz")
+		i := strings.LastIndex(base, "-")
+		if i < 0 {
+			continue
+		}
+		taken, err := time.Parse(snapshotTimeFormat, base[i+1:])
+		if err != nil {
+			continue
+		}
+		if applicationId != "" && base[:i] != applicationId {
+			continue
+		}
+
+		snapshots = append(snapshots, SnapshotInfo{Path: filepath.Join(absDir, name), ApplicationId: base[:i], Taken: taken})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Taken.Before(snapshots[j].Taken)
+	})
+	return snapshots, nil
+}
+
+// SnapshotAt returns the latest snapshot in dir taken at or before t.
+func SnapshotAt(dir string, applicationId string, t time.Time) (SnapshotInfo, error) {
+	snapshots, err := ListSnapshots(dir, applicationId)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].Taken.After(t) {
+			return snapshots[i], nil
+		}
+	}
+	return SnapshotInfo{}, fmt.Errorf("no snapshot taken on or before %s in %s", t.Format(time.RFC3339), dir)
+}
+
+// ParseSnapshotTime accepts a date ("2024-03-31", meaning the end of that day,
+// UTC) or an RFC 3339 timestamp.
+func ParseSnapshotTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", value)
+}
+
+// ResolveSnapshot finds a snapshot by file path or by date (see ParseSnapshotTime).
+func ResolveSnapshot(dir string, applicationId string, ref string) (*Snapshot, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return LoadSnapshot(ref)
+	}
+
+	t, err := ParseSnapshotTime(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a snapshot file nor a date", ref)
+	}
+
+	info, err := SnapshotAt(dir, applicationId, t)
+	if err != nil {
+		return nil, err
+	}
+	return LoadSnapshot(info.Path)
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SodRulesFile is where segregation-of-duties rules are read from unless
+// another file is given.
+//
+//	rules:
+//	  - name: request-approve
+//	    description: Nobody may both request and approve access
+//	    sodRoles: [Requester, Approver]
+//
+// A user or role holding two or more of a rule's SodRoles is a violation.
+const SodRulesFile = "~/.c3po/sod.yaml"
+
+// Violation subjects.
+const (
+	SodSubjectUser = "user"
+	SodSubjectRole = "role"
+)
+
+// SodRule declares a set of SodRoles that must not be held together.
+type SodRule struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	SodRoles    []string `json:"sodRoles" yaml:"sodRoles"`
+}
+
+// SodViolation is a user or role holding incompatible SodRoles. Paths lists
+// how each of the conflicting SodRoles is obtained.
+type SodViolation struct {
+	Rule     string
+	Subject  string
+	Name     string
+	SodRoles []string
+	Paths    []string
+}
+
+// LoadSodRules reads and validates a rules file.
+func LoadSodRules(path string) ([]SodRule, error) {
+	absPath, err := AbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Rules []SodRule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if err := ValidateSodRules(file.Rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file.Rules, nil
+}
+
+// ValidateSodRules checks that every rule has a unique name and at least two
+// distinct SodRoles.
+func ValidateSodRules(rules []SodRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("no rules")
+	}
+
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return fmt.Errorf("rule without a name")
+		}
+		if names[strings.ToLower(rule.Name)] {
+			return fmt.Errorf("rule %q is declared twice", rule.Name)
+		}
+		names[strings.ToLower(rule.Name)] = true
+
+		if len(sodRoleSet(rule.SodRoles)) < 2 {
+			return fmt.Errorf("rule %q needs at least two different sodRoles", rule.Name)
+		}
+	}
+	return nil
+}
+
+// UnknownSodRoles returns the SodRoles named by rules that no functional
+// ability carries, which usually means a typo in the rules file.
+func UnknownSodRoles(state *State, rules []SodRule) []string {
+	known := make(map[string]bool)
+	for _, ability := range state.FunctionalAbilities {
+		known[strings.ToLower(strings.TrimSpace(ability.SodRole))] = true
+	}
+
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		for _, sodRole := range rule.SodRoles {
+			key := strings.ToLower(strings.TrimSpace(sodRole))
+			if !known[key] && !seen[key] {
+				seen[key] = true
+				unknown = append(unknown, sodRole)
+			}
+		}
+	}
+	sortFold(unknown)
+	return unknown
+}
+
+// CheckSod evaluates every role definition and every user (through groups,
+// roles and abilities) against the rules. Violations are sorted by subject,
+// name and rule.
+func CheckSod(state *State, rules []SodRule) []SodViolation {
+	var violations []SodViolation
+
+	for _, role := range state.Roles {
+		held := make(map[string][]string)
+		for _, ability := range state.RoleAbilities(role) {
+			if key := sodRoleKey(ability.SodRole); key != "" {
+				held[key] = append(held[key], fmt.Sprintf("%s -> %s (%s)", role.Name, ability.Name, ability.SodRole))
+			}
+		}
+		violations = append(violations, sodViolations(rules, SodSubjectRole, role.Name, held)...)
+	}
+
+	users := make(map[string]map[string][]string)
+	var hubids []string
+	for _, path := range state.AccessPaths() {
+		key := sodRoleKey(path.Ability.SodRole)
+		if key == "" {
+			continue
+		}
+		hubid := path.User.IdAtSourceSystem
+		if users[hubid] == nil {
+			users[hubid] = make(map[string][]string)
+			hubids = append(hubids, hubid)
+		}
+		users[hubid][key] = append(users[hubid][key], fmt.Sprintf("%s (%s)", path, path.Ability.SodRole))
+	}
+	for _, hubid := range hubids {
+		violations = append(violations, sodViolations(rules, SodSubjectUser, hubid, users[hubid])...)
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if ka, kb := strings.ToLower(a.Name), strings.ToLower(b.Name); ka != kb {
+			return ka < kb
+		}
+		return a.Rule < b.Rule
+	})
+	return violations
+}
+
+// sodViolations checks one subject, given the paths to each SodRole it holds.
+func sodViolations(rules []SodRule, subject string, name string, held map[string][]string) []SodViolation {
+	var violations []SodViolation
+	for _, rule := range rules {
+		var sodRoles, paths []string
+		for key, sodRole := range sodRoleSet(rule.SodRoles) {
+			if len(held[key]) > 0 {
+				sodRoles = append(sodRoles, sodRole)
+				paths = append(paths, held[key]...)
+			}
+		}
+		if len(sodRoles) < 2 {
+			continue
+		}
+
+		sortFold(sodRoles)
+		sort.Strings(paths)
+		violations = append(violations, SodViolation{Rule: rule.Name, Subject: subject, Name: name, SodRoles: sodRoles, Paths: paths})
+	}
+	return violations
+}
+
+// sodRoleSet maps each distinct SodRole of a rule by its key.
+func sodRoleSet(sodRoles []string) map[string]string {
+	set := make(map[string]string)
+	for _, sodRole := range sodRoles {
+		if key := sodRoleKey(sodRole); key != "" {
+			set[key] = strings.TrimSpace(sodRole)
+		}
+	}
+	return set
+}
+
+func sodRoleKey(sodRole string) string {
+	return strings.ToLower(strings.TrimSpace(sodRole))
+}
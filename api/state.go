@@ -0,0 +1,168 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GroupState is a C3PO group together with its role bindings, attributes and members.
+type GroupState struct {
+	Group      Group             `json:"Group"`
+	Roles      []GroupRole       `json:"Roles"`
+	Attributes []GroupAttributes `json:"Attributes"`
+	Members    []User            `json:"Members"`
+}
+
+// State is everything Keystone holds for the application: roles, functional
+// abilities and every C3PO group with its bindings, attributes and members.
+type State struct {
+	ApplicationId       string                `json:"ApplicationId"`
+	Roles               []Role                `json:"Roles"`
+	FunctionalAbilities []FunctionalAbilities `json:"FunctionalAbilities"`
+	Groups              []GroupState          `json:"Groups"`
+}
+
+// FetchState reads the whole application from Keystone. Roles, abilities and
+// groups are sorted by name so that two states can be compared directly.
+func (c *Client) FetchState() (*State, error) {
+	roles, err := c.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	abilities, err := c.ListFunctionalAbilities()
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := c.ListGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{
+		ApplicationId:       c.c3poApplicationID,
+		Roles:               roles,
+		FunctionalAbilities: abilities,
+	}
+
+	for _, group := range groups {
+		if c.debug {
+			fmt.Println("Fetching group:", group.Name)
+		}
+
+		groupState := GroupState{Group: group}
+
+		if groupState.Roles, err = c.ListGroupRoles(group.Id); err != nil {
+			return nil, err
+		}
+		if groupState.Attributes, err = c.ListGroupAttributes(group.Id); err != nil {
+			return nil, err
+		}
+		if groupState.Members, err = c.ListGroupMembers(group.Id); err != nil {
+			return nil, err
+		}
+
+		state.Groups = append(state.Groups, groupState)
+	}
+
+	state.Sort()
+	return state, nil
+}
+
+// Sort orders roles, abilities, groups, bindings, attributes and members by
+// name (or id), so the state serializes deterministically.
+func (s *State) Sort() {
+	sort.SliceStable(s.Roles, func(i, j int) bool {
+		return strings.ToLower(s.Roles[i].Name) < strings.ToLower(s.Roles[j].Name)
+	})
+	sort.SliceStable(s.FunctionalAbilities, func(i, j int) bool {
+		return strings.ToLower(s.FunctionalAbilities[i].Name) < strings.ToLower(s.FunctionalAbilities[j].Name)
+	})
+	sort.SliceStable(s.Groups, func(i, j int) bool {
+		return strings.ToLower(s.Groups[i].Group.Name) < strings.ToLower(s.Groups[j].Group.Name)
+	})
+
+	for _, group := range s.Groups {
+		sort.SliceStable(group.Roles, func(i, j int) bool {
+			return group.Roles[i].RoleId < group.Roles[j].RoleId
+		})
+		sort.SliceStable(group.Attributes, func(i, j int) bool {
+			if group.Attributes[i].GroupRoleId != group.Attributes[j].GroupRoleId {
+				return group.Attributes[i].GroupRoleId < group.Attributes[j].GroupRoleId
+			}
+			return strings.ToLower(group.Attributes[i].AttributeName) < strings.ToLower(group.Attributes[j].AttributeName)
+		})
+		sort.SliceStable(group.Members, func(i, j int) bool {
+			return strings.ToLower(group.Members[i].IdAtSourceSystem) < strings.ToLower(group.Members[j].IdAtSourceSystem)
+		})
+	}
+}
+
+// Role returns the role with the given id.
+func (s *State) Role(id string) (Role, bool) {
+	for _, role := range s.Roles {
+		if role.Id == id {
+			return role, true
+		}
+	}
+	return Role{}, false
+}
+
+// RoleByName returns the role with the given name, ignoring case.
+func (s *State) RoleByName(name string) (Role, bool) {
+	for _, role := range s.Roles {
+		if strings.EqualFold(role.Name, name) {
+			return role, true
+		}
+	}
+	return Role{}, false
+}
+
+// FunctionalAbility returns the functional ability with the given id.
+func (s *State) FunctionalAbility(id string) (FunctionalAbilities, bool) {
+	for _, ability := range s.FunctionalAbilities {
+		if ability.Id == id {
+			return ability, true
+		}
+	}
+	return FunctionalAbilities{}, false
+}
+
+// FunctionalAbilityByName returns the functional ability with the given name, ignoring case.
+func (s *State) FunctionalAbilityByName(name string) (FunctionalAbilities, bool) {
+	for _, ability := range s.FunctionalAbilities {
+		if strings.EqualFold(ability.Name, name) {
+			return ability, true
+		}
+	}
+	return FunctionalAbilities{}, false
+}
+
+// GroupByName returns the group with the given name, ignoring case, repeated
+// spaces and the "C3PO - " prefix.
+func (s *State) GroupByName(name string) (GroupState, bool) {
+	key := groupKey(name)
+	for _, group := range s.Groups {
+		if groupKey(group.Group.Name) == key {
+			return group, true
+		}
+	}
+	return GroupState{}, false
+}
+
+// RoleAbilities returns the functional abilities assigned to role, sorted by name.
+// Ability ids unknown to the state are skipped.
+func (s *State) RoleAbilities(role Role) []FunctionalAbilities {
+	var abilities []FunctionalAbilities
+	for _, id := range RoleAbilityIds(role) {
+		if ability, ok := s.FunctionalAbility(id); ok {
+			abilities = append(abilities, ability)
+		}
+	}
+	sort.Slice(abilities, func(i, j int) bool {
+		return strings.ToLower(abilities[i].Name) < strings.ToLower(abilities[j].Name)
+	})
+	return abilities
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UserComparison is the group membership difference between two users:
+// the names of the groups only A is in, only B is in, and both are in.
+type UserComparison struct {
+	A      User     `json:"A"`
+	B      User     `json:"B"`
+	OnlyA  []string `json:"OnlyA"`
+	OnlyB  []string `json:"OnlyB"`
+	Common []string `json:"Common"`
+}
+
+// UserGroups returns the groups the user is a member of, in state order.
+func (s *State) UserGroups(user User) []GroupState {
+	var groups []GroupState
+	for _, group := range s.Groups {
+		if isMember(group, user) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+func isMember(group GroupState, user User) bool {
+	for _, member := range group.Members {
+		if sameUser(member, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompareUsers compares the group memberships of two users.
+func (s *State) CompareUsers(a User, b User) UserComparison {
+	comparison := UserComparison{A: a, B: b}
+	var groupsA, groupsB []string
+	for _, group := range s.UserGroups(a) {
+		groupsA = append(groupsA, group.Group.Name)
+	}
+	for _, group := range s.UserGroups(b) {
+		groupsB = append(groupsB, group.Group.Name)
+	}
+
+	comparison.OnlyB, comparison.OnlyA = diffNames(groupsA, groupsB)
+	for _, name := range groupsA {
+		if containsFold(groupsB, name) {
+			comparison.Common = append(comparison.Common, name)
+		}
+	}
+	sortFold(comparison.Common)
+	return comparison
+}
+
+// PlanCopyAccess plans adding the user to every group from is in and to is
+// not. With move, from is then removed from all of its groups.
+func (s *State) PlanCopyAccess(from User, to User, move bool) (*Plan, error) {
+	if sameUser(from, to) {
+		return nil, fmt.Errorf("%s and %s are the same user", from.IdAtSourceSystem, to.IdAtSourceSystem)
+	}
+	if !to.IsActive {
+		return nil, fmt.Errorf("user %q is not active", to.IdAtSourceSystem)
+	}
+
+	plan := &Plan{}
+	for _, group := range s.UserGroups(from) {
+		if isMember(group, to) {
+			continue
+		}
+		groupId, userId := group.Group.Id, to.Id
+		plan.Changes = append(plan.Changes, Change{
+			Action: ChangeCreate,
+			Kind:   KindMember,
+			Target: group.Group.Name + " / " + to.IdAtSourceSystem,
+			phase:  phaseMemberAdd,
+			apply: func(a *applier) error {
+				return a.client.AddGroupMember(groupId, userId)
+			},
+		})
+	}
+
+	if move {
+		for _, group := range s.UserGroups(from) {
+			groupId, userId := group.Group.Id, from.Id
+			plan.Changes = append(plan.Changes, Change{
+				Action: ChangeDelete,
+				Kind:   KindMember,
+				Target: group.Group.Name + " / " + from.IdAtSourceSystem,
+				phase:  phaseMemberRemove,
+				apply: func(a *applier) error {
+					return a.client.RemoveGroupMember(groupId, userId)
+				},
+			})
+		}
+	}
+	return plan, nil
+}
+
+// sameUser reports whether two users are the same, by Keystone id when both
+// have one and by HubID otherwise.
+func sameUser(a User, b User) bool {
+	if a.Id != "" && b.Id != "" {
+		return a.Id == b.Id
+	}
+	return strings.EqualFold(strings.TrimSpace(a.IdAtSourceSystem), strings.TrimSpace(b.IdAtSourceSystem))
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pAbilityName string
+var pClassification, pMinClassification string
+
+// AbilitiesCmd lists the application's functional abilities
+var AbilitiesCmd = &cobra.Command{
+	Use:     "abilities",
+	Aliases: []string{"ability"},
+	Short:   "List functional abilities",
+	Long: `List the functional abilities of the C3PO application with their description,
+data classification, SoD role and entity access.
+
+Use --ability to look one up by name, --role to only show the abilities assigned
+to the matching role(s), and --classification/--min-classification to filter by
+data classification level.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if err := c3po.LoadClassificationLevels(c3po.ClassificationLevelsFile); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		mode, err := c3po.ParseMatchMode(pMatchMode)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if pFuzzy {
+			mode = c3po.MatchFuzzy
+		}
+
+		abilities, err := sClient.FindFunctionalAbilities(pAbilityName, mode, 0)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		var roleAbilities map[string]bool
+		if pRoleName != "" {
+			matches, err := sClient.FindRoles(pRoleName, mode, 0)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			if len(matches) == 0 {
+				log.Fatalf("ERROR: no role matches %q", pRoleName)
+			}
+
+			roleAbilities = make(map[string]bool)
+			for _, match := range matches {
+				for _, id := range c3po.RoleAbilityIds(match.Role) {
+					roleAbilities[id] = true
+				}
+			}
+		}
+
+		var classification, minClassification int
+		if cmd.Flags().Changed("classification") {
+			if classification, err = c3po.ParseClassification(pClassification); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		}
+		if cmd.Flags().Changed("min-classification") {
+			if minClassification, err = c3po.ParseClassification(pMinClassification); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		}
+
+		var filtered []c3po.FunctionalAbilities
+		for _, ability := range abilities {
+			if roleAbilities != nil && !roleAbilities[ability.Id] {
+				continue
+			}
+			if cmd.Flags().Changed("classification") && ability.DataClassification != classification {
+				continue
+			}
+			if cmd.Flags().Changed("min-classification") && ability.DataClassification < minClassification {
+				continue
+			}
+			filtered = append(filtered, ability)
+		}
+
+		if pLimit > 0 && len(filtered) > pLimit {
+			filtered = filtered[:pLimit]
+		}
+
+		if len(filtered) == 0 && c3po.TextOutput() {
+			fmt.Println("No functional abilities found.")
+			return
+		}
+
+		if err := sClient.PrintFunctionalAbilities(filtered); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+func init() {
+
+	GetCmd.AddCommand(AbilitiesCmd)
+
+	AbilitiesCmd.Flags().StringVarP(&pAbilityName, "ability", "a", "", "Functional ability name (matched with --match)")
+	AbilitiesCmd.Flags().StringVarP(&pClassification, "classification", "c", "", "Only show abilities with exactly this data classification level (name or number)")
+	AbilitiesCmd.Flags().StringVarP(&pMinClassification, "min-classification", "", "", "Only show abilities with at least this data classification level (name or number)")
+
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pAttrName, pAttrValue, pUsageType, pGroupRoleID, pAttrRole string
+
+// AttributesCmd groups the attribute sub-commands
+var AttributesCmd = &cobra.Command{
+	Use:     "attributes",
+	Aliases: []string{"attribute", "attr"},
+	Short:   "Manage attributes on C3PO groups and group-role bindings",
+}
+
+var attributesGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "List the attributes of a group",
+	Run: func(cmd *cobra.Command, args []string) {
+		group := lookupAttributeGroup()
+
+		attrs, err := sClient.ListGroupAttributes(group.Id)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		var filtered []c3po.GroupAttributes
+		for _, attr := range attrs {
+			if pGroupRoleID != "" && attr.GroupRoleId != pGroupRoleID {
+				continue
+			}
+			if pAttrName != "" && !strings.EqualFold(attr.AttributeName, pAttrName) {
+				continue
+			}
+			filtered = append(filtered, attr)
+		}
+
+		if c3po.TextOutput() {
+			fmt.Println("Group:", group.Name)
+		}
+		if err := sClient.PrintGroupAttributes(filtered); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+var attributesSetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Add an attribute or change its value",
+	Run: func(cmd *cobra.Command, args []string) {
+		if pAttrName == "" {
+			log.Fatalf("ERROR: --name is required")
+		}
+
+		group := lookupAttributeGroup()
+
+		usageType := c3po.UsageTypeGroup
+		if pGroupRoleID != "" {
+			usageType = c3po.UsageTypeGroupRole
+		}
+		if pUsageType != "" {
+			var err error
+			if usageType, err = c3po.ParseUsageType(pUsageType); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		}
+
+		attr := c3po.GroupAttributes{
+			AttributeName:  pAttrName,
+			AttributeValue: pAttrValue,
+			GroupId:        group.Id,
+			GroupRoleId:    pGroupRoleID,
+			UsageType:      usageType,
+		}
+
+		attr, created, err := sClient.SetGroupAttribute(attr)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if created {
+			fmt.Printf("Added attribute %s = %s on %s\n", attr.AttributeName, attr.AttributeValue, group.Name)
+		} else {
+			fmt.Printf("Changed attribute %s = %s on %s\n", attr.AttributeName, attr.AttributeValue, group.Name)
+		}
+	},
+}
+
+var attributesUnsetCmd = &cobra.Command{
+	Use:   "unset",
+	Short: "Remove an attribute",
+	Run: func(cmd *cobra.Command, args []string) {
+		if pAttrName == "" {
+			log.Fatalf("ERROR: --name is required")
+		}
+
+		group := lookupAttributeGroup()
+
+		attrs, err := sClient.ListGroupAttributes(group.Id)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		attr, ok := c3po.FindGroupAttribute(attrs, pGroupRoleID, pAttrName)
+		if !ok {
+			log.Fatalf("ERROR: attribute %q is not set on %s", pAttrName, group.Name)
+		}
+
+		if err := sClient.DeleteGroupAttribute(group.Id, attr.Id); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		fmt.Printf("Removed attribute %s from %s\n", attr.AttributeName, group.Name)
+	},
+}
+
+// lookupAttributeGroup resolves --group and, when --role is given, sets
+// pGroupRoleID to the GroupRoleId of that role's binding on the group.
+func lookupAttributeGroup() c3po.Group {
+	if pGroupName == "" {
+		log.Fatalf("ERROR: --group is required")
+	}
+
+	group, err := sClient.LookupGroup(pGroupName)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	if pAttrRole != "" {
+		binding, err := sClient.GroupRoleBinding(group.Id, pAttrRole)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		pGroupRoleID = binding.Id
+	}
+	return group
+}
+
+func init() {
+
+	RootCmd.AddCommand(AttributesCmd)
+	AttributesCmd.AddCommand(attributesGetCmd, attributesSetCmd, attributesUnsetCmd)
+
+	AttributesCmd.PersistentFlags().StringVarP(&pGroupName, "group", "g", "", "Group Name. 'C3PO - ' is optional")
+	AttributesCmd.PersistentFlags().StringVarP(&pGroupRoleID, "group-role-id", "", "", "GroupRoleId of the group-role binding the attribute belongs to")
+	AttributesCmd.PersistentFlags().StringVarP(&pAttrRole, "role", "r", "", "Role whose binding on --group the attribute belongs to (instead of --group-role-id)")
+	AttributesCmd.PersistentFlags().StringVarP(&pAttrName, "name", "", "", "Attribute name")
+	attributesSetCmd.Flags().StringVarP(&pAttrValue, "value", "", "", "Attribute value")
+	attributesSetCmd.Flags().StringVarP(&pUsageType, "usage-type", "", "", "Usage type: group or grouprole (default: grouprole with --role/--group-role-id, group otherwise)")
+
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pBackupFile string
+var pRevert bool
+
+// BackupCmd writes a backup archive
+var BackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Save every role, group, role binding, attribute and member to a backup archive",
+	Long: `Write a tar.gz archive holding the whole C3PO configuration of the application:
+roles, functional abilities, groups, role bindings, attributes and members.
+
+The archive is self-describing: backup.json records the schema version, application
+id, environment, time and SHA-256 checksums of state.json (what 'c3po restore'
+reads) and manifest.yaml (the same data as an access manifest, for reading).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := pBackupFile
+		if path == "" {
+			path = fmt.Sprintf("c3po-%s-%s.tar.gz", sClient.ApplicationID(), time.Now().UTC().Format("20060102T150405Z"))
+		}
+
+		info, err := sClient.WriteBackup(path)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		fmt.Printf("Backup of application %s (%s) written to %s\n", info.ApplicationId, info.Environment, path)
+		printBackupCounts(os.Stdout, info)
+	},
+}
+
+// RestoreCmd restores from a backup archive
+var RestoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "Recreate roles, groups, role bindings, attributes and members missing since a backup",
+	Long: `Verify a backup archive written by 'c3po backup' and recreate whatever it holds
+that is missing now: roles, groups, role bindings, attributes and group members.
+With --revert, roles and attributes changed since the backup are set back too.
+
+Restore never deletes anything created since the backup. An object that cannot
+be restored is reported and the rest are restored anyway; restore then exits
+with status 1. Use --dry-run for a report of the requests restore would send.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		info, backup, err := c3po.ReadBackup(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Backup of application %s (%s) taken %s by %s, schema version %d\n",
+			info.ApplicationId, info.Environment, info.Created.Format(time.RFC3339), info.CreatedBy, info.SchemaVersion)
+		printBackupCounts(os.Stderr, info)
+		fmt.Fprintln(os.Stderr)
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if info.ApplicationId != state.ApplicationId {
+			log.Fatalf("ERROR: backup is for application %s, not %s", info.ApplicationId, state.ApplicationId)
+		}
+
+		plan, err := sClient.PlanRestore(backup, state, pRevert)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if len(plan.Changes) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing to restore.")
+			return
+		}
+
+		if err := sClient.PrintPlan(plan); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun && !confirm("Restore these objects?") {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return
+		}
+
+		// Every object is restored on its own: one that cannot be (a member no
+		// longer active) must not hold back the rest.
+		applied, failed := sClient.ApplyPlanAll(plan, state, func(change c3po.Change, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "FAILED  %s %s %q: %v\n", change.Action, change.Kind, change.Target, err)
+			} else if !pDryRun {
+				fmt.Fprintf(os.Stderr, "OK      %s %s %q\n", change.Action, change.Kind, change.Target)
+			}
+		})
+
+		if pDryRun {
+			fmt.Fprintln(os.Stderr, "\nDry run: nothing was restored.")
+			return
+		}
+		created, updated, _ := c3po.CountChanges(applied)
+		fmt.Fprintf(os.Stderr, "\nRestore complete: %d created, %d reverted", created, updated)
+		if len(failed) > 0 {
+			fmt.Fprintf(os.Stderr, ", %d failed (see FAILED above)\n", len(failed))
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, ".")
+	},
+}
+
+func printBackupCounts(w io.Writer, info *c3po.BackupInfo) {
+	var kinds []string
+	for kind := range info.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "  %-20s %d\n", kind, info.Counts[kind])
+	}
+}
+
+func init() {
+
+	RootCmd.AddCommand(BackupCmd, RestoreCmd)
+
+	BackupCmd.Flags().StringVarP(&pBackupFile, "file", "f", "", "Archive to write (default c3po-<application>-<time>.tar.gz)")
+
+	RestoreCmd.Flags().BoolVarP(&pRevert, "revert", "", false, "Also revert roles and attributes changed since the backup")
+
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pCleanupApply, pCleanupNoResolve bool
+var pCleanupAllowlist string
+
+// CleanupCmd groups the cleanup sub-commands
+var CleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Find and remove stale C3PO access",
+}
+
+var cleanupInactiveCmd = &cobra.Command{
+	Use:   "inactive",
+	Short: "Report, and with --apply remove, inactive or unknown users in C3PO groups",
+	Long: `Scan every C3PO group membership for users whose IsActive is false or whose
+HubID no longer resolves in Keystone, and report them by group and source system.
+With --no-resolve members are not looked up, so only IsActive as listed in the
+group counts.
+
+Nothing is removed without --apply. HubIDs in the allowlist file (one or more per
+line, '#' starts a comment; default ` + c3po.CleanupAllowlistFile + `, used when it
+exists) are reported but never removed.
+
+With --apply, exits with status 1 when a removal failed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		allowlist, err := readAllowlist(pCleanupAllowlist, cmd.Flags().Changed("allowlist"))
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		members, err := sClient.FindInactiveMembers(state, allowlist, !pCleanupNoResolve)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if len(members) == 0 && c3po.TextOutput() {
+			fmt.Printf("No inactive users in %d groups.\n", len(state.Groups))
+			return
+		}
+
+		if err := printInactiveMembers(members); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pCleanupApply {
+			return
+		}
+
+		var remove int
+		for _, member := range members {
+			if !member.Allowlisted {
+				remove++
+			}
+		}
+		if remove == 0 {
+			fmt.Fprintln(os.Stderr, "Every inactive user is allowlisted; nothing to remove.")
+			return
+		}
+		if !pDryRun && !confirm(fmt.Sprintf("Remove %d membership(s)?", remove)) {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return
+		}
+
+		failed := 0
+		sClient.RemoveInactiveMembers(members, func(member c3po.InactiveMember) {
+			if member.Status == c3po.MemberFailed {
+				failed++
+				fmt.Fprintf(os.Stderr, "FAILED  %s from %q: %s\n", member.User.IdAtSourceSystem, member.Group, member.Error)
+			} else if !pDryRun {
+				fmt.Fprintf(os.Stderr, "OK      %s from %q\n", member.User.IdAtSourceSystem, member.Group)
+			}
+		})
+
+		if pDryRun {
+			fmt.Fprintf(os.Stderr, "\nDry run: %d would be removed, %d failed, %d allowlisted.\n", remove-failed, failed, len(members)-remove)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\nCleanup complete: %d removed, %d failed, %d allowlisted.\n", remove-failed, failed, len(members)-remove)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// readAllowlist reads the HubIDs of an allowlist file, lowercased. A missing
+// file is an error only when it was named explicitly.
+func readAllowlist(path string, required bool) (map[string]bool, error) {
+	absPath, err := c3po.AbsolutePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(absPath); os.IsNotExist(err) && !required {
+		return nil, nil
+	}
+
+	hubids, err := readHubIDs(nil, absPath)
+	if err != nil {
+		return nil, err
+	}
+	allowlist := make(map[string]bool, len(hubids))
+	for _, hubid := range hubids {
+		allowlist[strings.ToLower(hubid)] = true
+	}
+	return allowlist, nil
+}
+
+func printInactiveMembers(members []c3po.InactiveMember) error {
+	columns := []c3po.Column{
+		{Name: "Group", Value: func(v interface{}) string { return v.(c3po.InactiveMember).Group }},
+		{Name: "SourceSystem", Value: func(v interface{}) string { return v.(c3po.InactiveMember).SourceSystem }},
+		{Name: "HubID", Value: func(v interface{}) string { return v.(c3po.InactiveMember).User.IdAtSourceSystem }},
+		{Name: "CommonName", Value: func(v interface{}) string { return v.(c3po.InactiveMember).User.CommonName }},
+		{Name: "Email", Value: func(v interface{}) string { return v.(c3po.InactiveMember).User.Email }},
+		{Name: "Reason", Value: func(v interface{}) string { return v.(c3po.InactiveMember).Reason }},
+		{Name: "Allowlisted", Value: func(v interface{}) string { return fmt.Sprint(v.(c3po.InactiveMember).Allowlisted) }},
+	}
+
+	return c3po.PrintItems(members, columns, func() {
+		users := make(map[string]bool)
+		groups := make(map[string]bool)
+		allowlisted := 0
+		for i, member := range members {
+			if i == 0 || member.Group != members[i-1].Group {
+				fmt.Println(member.Group)
+			}
+			if i == 0 || member.Group != members[i-1].Group || member.SourceSystem != members[i-1].SourceSystem {
+				source := member.SourceSystem
+				if source == "" {
+					source = "(no source system)"
+				}
+				fmt.Println("  " + source)
+			}
+
+			note := member.Reason
+			if member.Allowlisted {
+				note += ", allowlisted"
+				allowlisted++
+			}
+			fmt.Printf("    %s\t%s\t%s\t%s\n", member.User.IdAtSourceSystem, member.User.CommonName, member.User.Email, note)
+
+			users[strings.ToLower(member.User.IdAtSourceSystem)] = true
+			groups[member.GroupId] = true
+		}
+		fmt.Printf("\n%d membership(s) of %d user(s) in %d group(s), %d allowlisted.\n", len(members), len(users), len(groups), allowlisted)
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(CleanupCmd)
+	CleanupCmd.AddCommand(cleanupInactiveCmd)
+
+	cleanupInactiveCmd.Flags().BoolVarP(&pCleanupApply, "apply", "", false, "Remove the memberships found (after confirmation)")
+	cleanupInactiveCmd.Flags().StringVarP(&pCleanupAllowlist, "allowlist", "", c3po.CleanupAllowlistFile, "File of HubIDs never to remove")
+	cleanupInactiveCmd.Flags().BoolVarP(&pCleanupNoResolve, "no-resolve", "", false, "Do not look up members in Keystone; trust IsActive as listed")
+
+}
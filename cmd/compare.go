@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pFromEnv, pToEnv string
+var pPromoteRoles, pPromoteGroups []string
+var pPromoteAll bool
+
+// CompareCmd diffs two Keystone environments
+var CompareCmd = &cobra.Command{
+	Use:   "compare --from <env> --to <env>",
+	Short: "Compare roles, abilities and group role bindings between two environments",
+	Long: `Compare the roles, functional abilities and group role bindings of the C3PO
+application in two Keystone environments, matching objects by name.
+
+  + only in --from (promote would create it in --to)
+  ~ different (promote would update --to to match --from)
+  - only in --to
+
+Environments other than prod are declared in ` + c3po.EnvironmentsFile + `.`,
+	Annotations: offline,
+	Run: func(cmd *cobra.Command, args []string) {
+		from, _, to := fetchEnvironmentStates()
+
+		diffs := c3po.CompareManifests(from.Manifest(), to.Manifest())
+		if c3po.TextOutput() {
+			fmt.Printf("Differences from %s to %s:\n", pFromEnv, pToEnv)
+			if len(diffs) == 0 {
+				fmt.Println("  None.")
+				return
+			}
+		}
+		if err := c3po.PrintDifferences(diffs); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+// PromoteCmd replays roles and group role bindings from one environment into another
+var PromoteCmd = &cobra.Command{
+	Use:   "promote --from <env> --to <env> [--role NAME]... [--group NAME]... | --all",
+	Short: "Copy selected roles and group role bindings from one environment to another",
+	Long: `Copy the selected roles (description, conditional expression and functional
+abilities, matched by name) and group role bindings from --from into --to, after
+showing the plan. Functional abilities must already exist in --to. Bindings are
+only added: a group keeps the roles it is bound to in --to only. Nothing that is
+not selected is changed or deleted; members and attributes are never copied.`,
+	Annotations: offline,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !pPromoteAll && len(pPromoteRoles) == 0 && len(pPromoteGroups) == 0 {
+			log.Fatalf("ERROR: select what to promote with --role, --group or --all")
+		}
+
+		from, target, to := fetchEnvironmentStates()
+
+		manifest, err := c3po.PromotionManifest(from.Manifest(), to.Manifest(), pPromoteRoles, pPromoteGroups, pPromoteAll)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		plan, err := target.PlanManifest(manifest, to, c3po.PruneNone)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if len(plan.Changes) == 0 {
+			fmt.Fprintf(os.Stderr, "Nothing to promote: %s already matches %s.\n", pToEnv, pFromEnv)
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "Promotion plan from %s to %s:\n", pFromEnv, pToEnv)
+		if err := target.PrintPlan(plan); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun && !confirm("Apply these changes to "+pToEnv+"?") {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return
+		}
+
+		applied, err := target.ApplyPlan(plan, to, nil)
+		created, updated, deleted := c3po.CountChanges(applied)
+		fmt.Fprintf(os.Stderr, "Promotion complete: %d created, %d updated, %d deleted\n", created, updated, deleted)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// fetchEnvironmentStates logs in to --from and --to and fetches both states.
+// The --to client is returned for making changes.
+func fetchEnvironmentStates() (*c3po.State, *c3po.Client, *c3po.State) {
+	if pFromEnv == "" || pToEnv == "" {
+		log.Fatalf("ERROR: --from and --to are required")
+	}
+	if pFromEnv == pToEnv {
+		log.Fatalf("ERROR: --from and --to are the same environment")
+	}
+
+	from, err := loginEnvironment(pFromEnv).FetchState()
+	if err != nil {
+		log.Fatalf("ERROR: %s: %v", pFromEnv, err)
+	}
+
+	target := loginEnvironment(pToEnv)
+	to, err := target.FetchState()
+	if err != nil {
+		log.Fatalf("ERROR: %s: %v", pToEnv, err)
+	}
+	return from, target, to
+}
+
+func init() {
+
+	RootCmd.AddCommand(CompareCmd, PromoteCmd)
+
+	for _, c := range []*cobra.Command{CompareCmd, PromoteCmd} {
+		c.Flags().StringVarP(&pFromEnv, "from", "", "", "Source environment")
+		c.Flags().StringVarP(&pToEnv, "to", "", "", "Target environment")
+	}
+	PromoteCmd.Flags().StringSliceVarP(&pPromoteRoles, "role", "r", nil, "Role to promote (repeatable)")
+	PromoteCmd.Flags().StringSliceVarP(&pPromoteGroups, "group", "g", nil, "Group whose role bindings to promote (repeatable)")
+	PromoteCmd.Flags().BoolVarP(&pPromoteAll, "all", "", false, "Promote every role and group role binding")
+
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pEvalAttributes []string
+
+// EvalRoleCmd evaluates a role's conditional expression for a user
+var EvalRoleCmd = &cobra.Command{
+	Use:   "eval-role --role NAME --user HUBID [--attr NAME=VALUE]...",
+	Short: "Evaluate a role's conditional expression against a user's attributes",
+	Long: `Parse the role's ConditionalExpression, print it, and evaluate it locally against
+the user's Keystone attributes (CommonName, Email, FirstName, LastName, IsActive,
+SourceSystemName, ...), predicting whether the user would be assigned the role
+without waiting for Keystone to recompute dynamic membership.
+
+--attr adds or overrides attributes, to test "what if" cases. Every comparison
+is printed with the value it used. Exits with status 1 when the expression does
+not match.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pRoleName == "" || pUserID == "" {
+			log.Fatalf("ERROR: --role and --user are required")
+		}
+
+		role, err := sClient.LookupRole(pRoleName)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		user, err := sClient.LookupUser(pUserID)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		expression, err := c3po.ParseExpression(role.ConditionalExpression)
+		if err != nil {
+			log.Fatalf("ERROR: role %s: %v", role.Name, err)
+		}
+		if expression == nil {
+			fmt.Printf("Role %s has no conditional expression.\n", role.Name)
+			return
+		}
+
+		attrs := c3po.UserAttributes(user)
+		for _, attr := range pEvalAttributes {
+			name, value, ok := strings.Cut(attr, "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				log.Fatalf("ERROR: --attr %q: expected NAME=VALUE", attr)
+			}
+			attrs[strings.TrimSpace(name)] = value
+		}
+
+		matched, trace := c3po.EvaluateExpression(expression, attrs)
+
+		fmt.Printf("Role:       %s\n", role.Name)
+		if dynamic := role.DynamicAssignmentId; dynamic != nil && fmt.Sprint(dynamic) != "" {
+			fmt.Printf("Dynamic:    DynamicAssignmentId %v\n", dynamic)
+		}
+		fmt.Printf("Expression: %s\n", expression)
+		fmt.Printf("User:       %s (%s)\n\n", user.IdAtSourceSystem, user.CommonName)
+		for _, line := range trace {
+			fmt.Println("  " + line)
+		}
+
+		if !matched {
+			fmt.Printf("\n%s does NOT match the conditions of %s.\n", user.IdAtSourceSystem, role.Name)
+			os.Exit(1)
+		}
+		fmt.Printf("\n%s matches the conditions of %s.\n", user.IdAtSourceSystem, role.Name)
+	},
+}
+
+func init() {
+
+	RootCmd.AddCommand(EvalRoleCmd)
+
+	EvalRoleCmd.Flags().StringVarP(&pRoleName, "role", "r", "", "Role name")
+	EvalRoleCmd.Flags().StringVarP(&pUserID, "user", "u", "", "HubID of the user")
+	EvalRoleCmd.Flags().StringArrayVarP(&pEvalAttributes, "attr", "", nil, "Add or override a user attribute (NAME=VALUE, repeatable)")
+
+}
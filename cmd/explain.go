@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+// ExplainCmd traces why a user holds a role or functional ability
+var ExplainCmd = &cobra.Command{
+	Use:   "explain --user HUBID (--ability NAME | --role NAME)",
+	Short: "Show every path through which a user holds a functional ability or role",
+	Long: `Build the user -> group -> role -> functional ability -> entity access graph and
+print every path that gives the user the functional ability (--ability) or the
+role (--role). Paths through dynamically assigned groups or roles, and through
+roles with a conditional expression, are marked; conditional expressions are
+evaluated against the user's attributes (see 'c3po eval-role').`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pUserID == "" {
+			log.Fatalf("ERROR: --user is required")
+		}
+		if (pAbilityName == "") == (pRoleName == "") {
+			log.Fatalf("ERROR: give either --ability or --role")
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		name, kind := pRoleName, "role"
+		if pAbilityName != "" {
+			name, kind = pAbilityName, "functional ability"
+		}
+
+		explanations, err := state.ExplainAccess(pUserID, name, pAbilityName != "")
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if c3po.TextOutput() {
+			if len(explanations) == 0 {
+				fmt.Printf("%s does not have %s %q.\n", pUserID, kind, name)
+				return
+			}
+			fmt.Printf("%s has %s %q through %d path(s):\n\n", pUserID, kind, name, len(explanations))
+		}
+
+		if err := printExplanations(explanations); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+func printExplanations(explanations []c3po.Explanation) error {
+	columns := []c3po.Column{
+		{Name: "HubID", Value: func(v interface{}) string { return v.(c3po.Explanation).HubID }},
+		{Name: "Group", Value: func(v interface{}) string { return v.(c3po.Explanation).Group }},
+		{Name: "Role", Value: func(v interface{}) string { return v.(c3po.Explanation).Role }},
+		{Name: "Ability", Value: func(v interface{}) string { return v.(c3po.Explanation).Ability }},
+		{Name: "EntityAccess", Value: func(v interface{}) string { return strings.Join(v.(c3po.Explanation).EntityAccess, "; ") }},
+		{Name: "DynamicGroup", Value: func(v interface{}) string { return v.(c3po.Explanation).DynamicGroup }},
+		{Name: "DynamicRole", Value: func(v interface{}) string { return v.(c3po.Explanation).DynamicRole }},
+		{Name: "Condition", Value: func(v interface{}) string { return v.(c3po.Explanation).Condition }},
+		{Name: "ConditionResult", Value: func(v interface{}) string { return v.(c3po.Explanation).ConditionResult }},
+	}
+
+	return c3po.PrintItems(explanations, columns, func() {
+		for _, explanation := range explanations {
+			fmt.Println("  " + explanation.Path)
+			if explanation.DynamicGroup != "" {
+				fmt.Println("        group membership is dynamic (DynamicAssignmentId " + explanation.DynamicGroup + ")")
+			}
+			if explanation.DynamicRole != "" {
+				fmt.Println("        role is dynamically assigned (DynamicAssignmentId " + explanation.DynamicRole + ")")
+			}
+			if explanation.Condition != "" {
+				fmt.Println("        role condition " + explanation.Condition + " (" + explanation.ConditionResult + ")")
+			}
+			for _, entity := range explanation.EntityAccess {
+				fmt.Println("        entity access: " + entity)
+			}
+		}
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(ExplainCmd)
+
+	ExplainCmd.Flags().StringVarP(&pUserID, "user", "u", "", "HubID of the user")
+	ExplainCmd.Flags().StringVarP(&pAbilityName, "ability", "a", "", "Functional ability name (exact, case-insensitive)")
+	ExplainCmd.Flags().StringVarP(&pRoleName, "role", "r", "", "Role name (exact, case-insensitive)")
+
+}
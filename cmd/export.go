@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pExportFile, pExportDir, pExportFormat string
+
+// ExportCmd writes the application state as a manifest
+var ExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export every role, group, binding, attribute, ability and member to a manifest",
+	Long: `Export the whole application as an access manifest that 'c3po plan' and
+'c3po apply' understand. The output is normalized and sorted so that successive
+exports can be committed to git and diffed.
+
+Without --file or --dir the manifest is printed on standard output. A --file
+ending in .json is written as JSON, any other as YAML. With --dir one file per
+role, group and functional ability is written under roles/, groups/ and
+abilities/.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON := false
+		switch pExportFormat {
+		case "yaml", "yml":
+		case "json":
+			asJSON = true
+		default:
+			log.Fatalf("ERROR: unknown format %q (expected yaml or json)", pExportFormat)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		manifest := state.Manifest()
+
+		switch {
+		case pExportDir != "":
+			if err := c3po.WriteManifestTree(pExportDir, manifest, asJSON); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			fmt.Fprintf(os.Stderr, "Exported %d roles, %d groups and %d functional abilities to %s\n",
+				len(manifest.Roles), len(manifest.Groups), len(manifest.FunctionalAbilities), pExportDir)
+		case pExportFile != "":
+			// The manifest is read back by the extension, so it decides the format.
+			fileJSON := strings.EqualFold(filepath.Ext(pExportFile), ".json")
+			if cmd.Flags().Changed("format") && asJSON != fileJSON {
+				log.Fatalf("ERROR: --format %s does not match the extension of %s", pExportFormat, pExportFile)
+			}
+			if err := c3po.WriteManifest(pExportFile, manifest); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			fmt.Fprintf(os.Stderr, "Exported %d roles, %d groups and %d functional abilities to %s\n",
+				len(manifest.Roles), len(manifest.Groups), len(manifest.FunctionalAbilities), pExportFile)
+		default:
+			data, err := c3po.MarshalManifest(manifest, asJSON)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			os.Stdout.Write(data)
+		}
+	},
+}
+
+func init() {
+
+	RootCmd.AddCommand(ExportCmd)
+
+	ExportCmd.Flags().StringVarP(&pExportFile, "file", "f", "", "Write the manifest to this file")
+	ExportCmd.Flags().StringVarP(&pExportDir, "dir", "", "", "Write one file per object under this directory")
+	ExportCmd.Flags().StringVarP(&pExportFormat, "format", "", "yaml", "Manifest format: yaml or json")
+
+}
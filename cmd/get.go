@@ -3,62 +3,111 @@ package cmd
 import (
 	"log"
 	"fmt"
+	"os"
+
+	c3po "github.com/comdol2/c3po/api"
 	"github.com/spf13/cobra"
 )
 
-type Role struct {
-	ApplicationId          string      `json:"ApplicationId"`
-	Name                   string      `json:"Name"`
-	Description            string      `json:"Description"`
-	ConditionalExpression  interface{} `json:"ConditionalExpression"`
-	DynamicAssignmentId    interface{} `json:"DynamicAssignmentId"`
-	RoleFunctionalAbilities interface{} `json:"RoleFunctionalAbilities"`
-	Id                     string      `json:"Id"`
-	LastUpdate             string      `json:"LastUpdate"`
-}
+var pGroupName, pRoleName, pNimbusFolderName, pUserID, pMatchMode string
+var pMyGroup, pFuzzy bool
+var pLimit int
 
-var pGroupName, pRoleName, pNimbusFolderName, pUserID string
-var pMyGroup bool
+// maxSuggestions caps the "did you mean" list printed when nothing matched.
+const maxSuggestions = 5
 
 // listCmd represents the list command
 var GetCmd = &cobra.Command{
 	Use:   "get",
 	Short: "get test code",
 	Long:  `this is GET code`,
-        Run: func(cmd *cobra.Command, args []string) {
+	Run: func(cmd *cobra.Command, args []string) {
+
+		mode, err := c3po.ParseMatchMode(pMatchMode)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if pFuzzy {
+			mode = c3po.MatchFuzzy
+		}
 
-		
 		if pRoleName != "" {
-			res, err := sClient.GetRole(pRoleName, false)
+			all, err := sClient.ListRoles()
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			matches, err := sClient.MatchRoles(all, pRoleName, mode, pLimit)
 			if err != nil {
 				log.Fatalf("ERROR: %v", err)
 			}
 
-			var roles Role
-			for _, roleMap := range res {
-				role := Role{
-					ApplicationId:          roleMap["ApplicationId"].(string),
-					Name:                   roleMap["Name"].(string),
-					Description:            roleMap["Description"].(string),
-					ConditionalExpression:  roleMap["ConditionalExpression"],
-					DynamicAssignmentId:    roleMap["DynamicAssignmentId"],
-					RoleFunctionalAbilities: roleMap["RoleFunctionalAbilities"],
-					Id:                     roleMap["Id"].(string),
-					LastUpdate:             roleMap["LastUpdate"].(string),
+			if len(matches) == 0 {
+				suggestions := sClient.SuggestRoles(all, pRoleName, maxSuggestions)
+				fmt.Fprintf(os.Stderr, "No role matches %q.\n", pRoleName)
+				for i, suggestion := range suggestions {
+					if i == 0 {
+						fmt.Fprintln(os.Stderr, "Did you mean:")
+					}
+					fmt.Fprintf(os.Stderr, "\t%s\n", suggestion.Role.Name)
+				}
+				if c3po.TextOutput() {
+					return
 				}
-				roles = append(roles, role)
 			}
 
-			sClient.PrintRoles(roles)
-		} else {
-			fmt.Println("No RoleName!")	
-		}
-
-
+			if mode == c3po.MatchFuzzy {
+				err = sClient.PrintRoleMatches(matches)
+			} else {
+				var roles []c3po.Role
+				for _, match := range matches {
+					roles = append(roles, match.Role)
+				}
+				err = sClient.PrintRoles(roles)
+			}
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		} else if pGroupName != "" {
+			all, err := sClient.ListGroups()
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			matches, err := sClient.MatchGroups(all, pGroupName, mode, pLimit)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
 
-        },
+			if len(matches) == 0 {
+				suggestions := sClient.SuggestGroups(all, pGroupName, maxSuggestions)
+				fmt.Fprintf(os.Stderr, "No group matches %q.\n", pGroupName)
+				for i, suggestion := range suggestions {
+					if i == 0 {
+						fmt.Fprintln(os.Stderr, "Did you mean:")
+					}
+					fmt.Fprintf(os.Stderr, "\t%s\n", suggestion.Group.Name)
+				}
+				if c3po.TextOutput() {
+					return
+				}
+			}
 
+			if mode == c3po.MatchFuzzy {
+				err = sClient.PrintGroupMatches(matches)
+			} else {
+				var groups []c3po.Group
+				for _, match := range matches {
+					groups = append(groups, match.Group)
+				}
+				err = sClient.PrintGroups(groups)
+			}
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		} else {
+			fmt.Println("No RoleName!")
+		}
 
+	},
 }
 
 func init() {
@@ -70,6 +119,9 @@ func init() {
         GetCmd.PersistentFlags().StringVarP(&pNimbusFolderName, "nimbusfolder", "n", "", "Nimbus Folder Name which is kwown as application name.")
         GetCmd.PersistentFlags().StringVarP(&pUserID, "userid", "u", "", "HUBID")
 	GetCmd.PersistentFlags().BoolVarP(&pMyGroup, "mygroup", "", false, "Get all of my groups where I am an approval manager or just a member")
+	GetCmd.PersistentFlags().StringVarP(&pMatchMode, "match", "m", "auto", "How to match --role/--group: auto (ranked substring), exact, glob, regex or fuzzy")
+	GetCmd.PersistentFlags().BoolVarP(&pFuzzy, "fuzzy", "", false, "Same as --match fuzzy: score names by similarity to tolerate misspellings")
+	GetCmd.PersistentFlags().IntVarP(&pLimit, "limit", "l", 0, "Maximum number of matches to show (0 = all)")
 
 }
 
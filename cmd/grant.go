@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pGrantFor, pGrantReason, pGrantLedger string
+var pGrantAll bool
+
+// GrantCmd adds a time-bound group membership
+var GrantCmd = &cobra.Command{
+	Use:   "grant --user HUBID --group NAME --for DURATION",
+	Short: "Add a user to a group until an expiry recorded in the grant ledger",
+	Long: `Add a user to a C3PO group for a limited time (--for 14d, 2w or 36h) and record
+the expiry in the grant ledger (default ` + c3po.GrantLedgerFile + `; use --ledger
+to share one file between operators). Granting again before expiry extends the
+grant from now. 'c3po grant reap' removes the expired memberships and is meant
+to run from cron with --yes, logging in with the --credentials file.
+
+Users already in the group without a grant are refused, since their access is
+permanent.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pUserID == "" || pGroupName == "" || pGrantFor == "" {
+			log.Fatalf("ERROR: --user, --group and --for are required")
+		}
+		d, err := c3po.ParseGrantDuration(pGrantFor)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		user, err := sClient.LookupUser(pUserID)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !user.IsActive {
+			log.Fatalf("ERROR: user %q is not active", user.IdAtSourceSystem)
+		}
+		group, err := sClient.LookupGroup(pGroupName)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		grants, err := c3po.ReadGrantLedger(pGrantLedger)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		members, err := sClient.ListGroupMembers(group.Id)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		member := false
+		for _, m := range members {
+			if m.Id == user.Id {
+				member = true
+			}
+		}
+
+		grant := sClient.NewGrant(user, group, d, pGrantReason)
+		i, extend := c3po.ActiveGrant(grants, group.Id, user.Id)
+		if extend {
+			fmt.Printf("Extend grant %s of %q to %s (%s) from %s to %s\n", grants[i].Id, group.Name, user.IdAtSourceSystem, user.CommonName,
+				grants[i].Expires.Local().Format(time.RFC1123), grant.Expires.Local().Format(time.RFC1123))
+		} else {
+			if member {
+				log.Fatalf("ERROR: %s is already a member of %q without a grant", user.IdAtSourceSystem, group.Name)
+			}
+			fmt.Printf("Grant %q to %s (%s) until %s\n", group.Name, user.IdAtSourceSystem, user.CommonName, grant.Expires.Local().Format(time.RFC1123))
+		}
+		if !pDryRun && !confirm("Proceed?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		if pDryRun {
+			if !extend {
+				if err := sClient.AddGroupMember(group.Id, user.Id); err != nil {
+					log.Fatalf("ERROR: %v", err)
+				}
+			}
+			fmt.Println("[dry-run] grant not recorded in", pGrantLedger)
+			return
+		}
+
+		// Record the grant before adding the membership, so that no membership
+		// is ever left without an expiry.
+		err = c3po.UpdateGrantLedger(pGrantLedger, func(grants []c3po.Grant) ([]c3po.Grant, error) {
+			if i, ok := c3po.ActiveGrant(grants, group.Id, user.Id); ok {
+				grants[i].Expires = grant.Expires
+				grant = grants[i]
+				return grants, nil
+			}
+			return append(grants, grant), nil
+		})
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if !member {
+			if err := sClient.AddGroupMember(group.Id, user.Id); err != nil {
+				removeErr := c3po.UpdateGrantLedger(pGrantLedger, func(grants []c3po.Grant) ([]c3po.Grant, error) {
+					var kept []c3po.Grant
+					for _, g := range grants {
+						if g.Id != grant.Id {
+							kept = append(kept, g)
+						}
+					}
+					return kept, nil
+				})
+				if removeErr != nil {
+					log.Printf("ERROR: removing grant %s from the ledger: %v", grant.Id, removeErr)
+				}
+				log.Fatalf("ERROR: %v", err)
+			}
+		}
+		fmt.Printf("Granted %q to %s until %s (grant %s)\n", group.Name, user.IdAtSourceSystem, grant.Expires.Local().Format(time.RFC1123), grant.Id)
+	},
+}
+
+var grantReapCmd = &cobra.Command{
+	Use:   "reap",
+	Short: "Remove the memberships of every expired grant",
+	Long: `Remove the group membership of every expired grant of the application in the
+ledger and mark the grants revoked. Users who already left the group are only
+marked. Run it from cron with --yes; the login then comes from the --credentials
+file (default ` + c3po.CredentialsFile + `). Exits with status 1 when a grant could
+not be revoked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		grants, err := c3po.ReadGrantLedger(pGrantLedger)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		now := time.Now()
+		var list []c3po.Grant
+		for _, grant := range grants {
+			if grant.ApplicationId == sClient.ApplicationID() && grant.Status(now) == c3po.GrantExpired {
+				list = append(list, grant)
+			}
+		}
+		if len(list) == 0 {
+			if c3po.TextOutput() {
+				fmt.Println("No expired grants.")
+			}
+			return
+		}
+
+		if err := printGrants(list, now); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun && !confirm(fmt.Sprintf("Revoke %d expired grant(s)?", len(list))) {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return
+		}
+
+		// Memberships are removed without holding the ledger lock, which is
+		// only taken to record the revocations.
+		revoked, failed := 0, 0
+		results := make(map[string]c3po.Grant)
+		for _, grant := range list {
+			if err := sClient.RevokeGrant(&grant); err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "FAILED  %s from %q: %v\n", grant.HubID, grant.Group, err)
+				continue
+			}
+			revoked++
+			results[grant.Id] = grant
+			if !pDryRun {
+				fmt.Fprintf(os.Stderr, "OK      %s from %q\n", grant.HubID, grant.Group)
+			}
+		}
+
+		if !pDryRun && len(results) > 0 {
+			err := c3po.UpdateGrantLedger(pGrantLedger, func(grants []c3po.Grant) ([]c3po.Grant, error) {
+				for i := range grants {
+					result, ok := results[grants[i].Id]
+					if !ok || grants[i].Revoked != nil {
+						continue
+					}
+					if grants[i].Status(time.Now()) == c3po.GrantActive {
+						fmt.Fprintf(os.Stderr, "WARNING grant %s of %q to %s was extended while it was reaped; grant it again\n", grants[i].Id, grants[i].Group, grants[i].HubID)
+					}
+					grants[i].Revoked, grants[i].RevokedBy = result.Revoked, result.RevokedBy
+				}
+				return grants, nil
+			})
+			if err != nil {
+				log.Fatalf("ERROR: recording %d revocation(s) in %s: %v", len(results), pGrantLedger, err)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "\nReap complete: %d revoked, %d failed.\n", revoked, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var grantListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the active and expired grants of the application",
+	Run: func(cmd *cobra.Command, args []string) {
+		grants, err := c3po.ReadGrantLedger(pGrantLedger)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		now := time.Now()
+		var list []c3po.Grant
+		for _, grant := range grants {
+			if grant.ApplicationId == sClient.ApplicationID() && (pGrantAll || grant.Status(now) != c3po.GrantRevoked) {
+				list = append(list, grant)
+			}
+		}
+		if len(list) == 0 && c3po.TextOutput() {
+			fmt.Println("No grants in", pGrantLedger)
+			return
+		}
+
+		if err := printGrants(list, now); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+func printGrants(grants []c3po.Grant, now time.Time) error {
+	columns := []c3po.Column{
+		{Name: "Id", Value: func(v interface{}) string { return v.(c3po.Grant).Id }},
+		{Name: "HubID", Value: func(v interface{}) string { return v.(c3po.Grant).HubID }},
+		{Name: "Group", Value: func(v interface{}) string { return v.(c3po.Grant).Group }},
+		{Name: "Status", Value: func(v interface{}) string { return v.(c3po.Grant).Status(now) }},
+		{Name: "Expires", Value: func(v interface{}) string { return v.(c3po.Grant).Expires.Format(time.RFC3339) }},
+		{Name: "Granted", Value: func(v interface{}) string { return v.(c3po.Grant).Granted.Format(time.RFC3339) }},
+		{Name: "GrantedBy", Value: func(v interface{}) string { return v.(c3po.Grant).GrantedBy }},
+		{Name: "Reason", Value: func(v interface{}) string { return v.(c3po.Grant).Reason }},
+	}
+
+	return c3po.PrintItems(grants, columns, func() {
+		for _, grant := range grants {
+			status := grant.Status(now)
+			switch status {
+			case c3po.GrantActive:
+				status = "expires in " + grant.Expires.Sub(now).Round(time.Minute).String()
+			case c3po.GrantExpired:
+				status = "EXPIRED " + now.Sub(grant.Expires).Round(time.Minute).String() + " ago"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", grant.Id, grant.HubID, grant.Group, grant.Expires.Local().Format("2006-01-02 15:04"), status)
+			if grant.Reason != "" {
+				fmt.Printf("\t%s (granted by %s)\n", grant.Reason, grant.GrantedBy)
+			}
+		}
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(GrantCmd)
+	GrantCmd.AddCommand(grantReapCmd, grantListCmd)
+
+	GrantCmd.Flags().StringVarP(&pUserID, "user", "u", "", "HubID of the user")
+	GrantCmd.Flags().StringVarP(&pGroupName, "group", "g", "", "Group name")
+	GrantCmd.Flags().StringVarP(&pGrantFor, "for", "", "", "How long the grant lasts: 14d, 2w, 36h...")
+	GrantCmd.Flags().StringVarP(&pGrantReason, "reason", "", "", "Why the access is granted (recorded in the ledger)")
+	GrantCmd.PersistentFlags().StringVarP(&pGrantLedger, "ledger", "", c3po.GrantLedgerFile, "Grant ledger file")
+	grantListCmd.Flags().BoolVarP(&pGrantAll, "all", "", false, "Also list revoked grants")
+
+}
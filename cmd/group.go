@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// GroupCmd groups the group sub-commands
+var GroupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage C3PO groups, their members and their roles",
+	Long: `Create, rename and delete C3PO groups, and manage their members and role bindings.
+
+Group names always follow the 'C3PO - <Role/Studio Name>' convention: the prefix
+is added when missing, repeated spaces are collapsed and names ending with a dash
+or clashing (case-insensitively) with an existing group are refused.`,
+}
+
+var groupCreateCmd = &cobra.Command{
+	Use:   "create <group name>",
+	Short: "Create a group",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, err := sClient.GroupName(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if existing, err := sClient.LookupGroup(name); err == nil {
+			log.Fatalf("ERROR: group %q already exists as %q", name, existing.Name)
+		}
+
+		fmt.Printf("Group to create: %s\n", name)
+		if !pDryRun && !confirm("Create group "+name+"?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		group, err := sClient.CreateGroup(name)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun {
+			fmt.Printf("Created group %s (%s)\n", group.Name, group.Id)
+		}
+	},
+}
+
+var groupRenameCmd = &cobra.Command{
+	Use:   "rename <group name> <new group name>",
+	Short: "Rename a group",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		group, err := sClient.LookupGroup(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		name, err := sClient.GroupName(args[1])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		fmt.Printf("Group to rename: %s => %s\n", group.Name, name)
+		if !pDryRun && !confirm("Rename group "+group.Name+"?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		renamed, err := sClient.RenameGroup(group, name)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun {
+			fmt.Printf("Renamed group %s to %s\n", group.Name, renamed.Name)
+		}
+	},
+}
+
+var groupDeleteCmd = &cobra.Command{
+	Use:   "delete <group name>",
+	Short: "Delete a group",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		group, err := sClient.LookupGroup(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		fmt.Printf("Group to delete: %s (%s)\n", group.Name, group.Id)
+		if !pDryRun && !confirm("Delete group "+group.Name+"?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		if err := sClient.DeleteGroup(group.Id); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun {
+			fmt.Printf("Deleted group %s\n", group.Name)
+		}
+	},
+}
+
+func init() {
+
+	RootCmd.AddCommand(GroupCmd)
+	GroupCmd.AddCommand(groupCreateCmd, groupRenameCmd, groupDeleteCmd)
+
+}
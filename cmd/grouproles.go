@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+// groupRolesCmd groups the role binding sub-commands
+var groupRolesCmd = &cobra.Command{
+	Use:     "roles",
+	Aliases: []string{"role"},
+	Short:   "List, assign and unassign the roles of a C3PO group",
+}
+
+var groupRolesListCmd = &cobra.Command{
+	Use:   "list <group name>",
+	Short: "List the roles bound to a group",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		group, err := sClient.LookupGroup(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		bindings, err := sClient.ListGroupRoles(group.Id)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		roles, err := sClient.ListRoles()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if c3po.TextOutput() {
+			fmt.Printf("Group: %s (%d roles)\n", group.Name, len(bindings))
+		}
+		if err := printGroupRoles(bindings, roles); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+var groupRolesAssignCmd = &cobra.Command{
+	Use:   "assign <group name> <role name>...",
+	Short: "Bind roles to a group",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		group, roles := lookupGroupAndRoles(args)
+
+		fmt.Printf("Roles to assign to %s: %s\n", group.Name, strings.Join(roleNames(roles), ", "))
+		if !pDryRun && !confirm("Assign "+fmt.Sprint(len(roles))+" role(s)?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		for _, role := range roles {
+			binding, err := sClient.AssignRole(group.Id, role.Id)
+			if err != nil {
+				log.Fatalf("ERROR: assigning %s: %v", role.Name, err)
+			}
+			if !pDryRun {
+				fmt.Printf("Assigned %s to %s (GroupRoleId %s)\n", role.Name, group.Name, binding.Id)
+			}
+		}
+	},
+}
+
+var groupRolesUnassignCmd = &cobra.Command{
+	Use:   "unassign <group name> <role name>...",
+	Short: "Remove role bindings from a group",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		group, roles := lookupGroupAndRoles(args)
+
+		bindings, err := sClient.ListGroupRoles(group.Id)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		var unassign []c3po.GroupRole
+		for _, role := range roles {
+			binding, ok := c3po.FindGroupRole(bindings, role.Id)
+			if !ok {
+				log.Fatalf("ERROR: role %q is not assigned to %s", role.Name, group.Name)
+			}
+			unassign = append(unassign, binding)
+		}
+
+		fmt.Printf("Roles to unassign from %s: %s\n", group.Name, strings.Join(roleNames(roles), ", "))
+		if !pDryRun && !confirm("Unassign "+fmt.Sprint(len(roles))+" role(s)?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		for i, binding := range unassign {
+			if err := sClient.UnassignRole(group.Id, binding.Id); err != nil {
+				log.Fatalf("ERROR: unassigning %s: %v", roles[i].Name, err)
+			}
+			if !pDryRun {
+				fmt.Printf("Unassigned %s from %s (GroupRoleId %s)\n", roles[i].Name, group.Name, binding.Id)
+			}
+		}
+	},
+}
+
+func lookupGroupAndRoles(args []string) (c3po.Group, []c3po.Role) {
+	group, err := sClient.LookupGroup(args[0])
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	var roles []c3po.Role
+	for _, name := range args[1:] {
+		role, err := sClient.LookupRole(name)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		roles = append(roles, role)
+	}
+	return group, roles
+}
+
+// roleBinding is a group role binding with the name of its role.
+type roleBinding struct {
+	Role        string
+	GroupRoleId string
+	RoleId      string
+}
+
+func printGroupRoles(bindings []c3po.GroupRole, roles []c3po.Role) error {
+	names := make(map[string]string, len(roles))
+	for _, role := range roles {
+		names[role.Id] = role.Name
+	}
+
+	var rows []roleBinding
+	for _, binding := range bindings {
+		name := names[binding.RoleId]
+		if name == "" {
+			name = "<unknown role " + binding.RoleId + ">"
+		}
+		rows = append(rows, roleBinding{Role: name, GroupRoleId: binding.Id, RoleId: binding.RoleId})
+	}
+
+	columns := []c3po.Column{
+		{Name: "Role", Value: func(v interface{}) string { return v.(roleBinding).Role }},
+		{Name: "GroupRoleId", Value: func(v interface{}) string { return v.(roleBinding).GroupRoleId }},
+		{Name: "RoleId", Value: func(v interface{}) string { return v.(roleBinding).RoleId }},
+	}
+
+	return c3po.PrintItems(rows, columns, func() {
+		for _, row := range rows {
+			fmt.Printf("%s\tGroupRoleId: %s\n", row.Role, row.GroupRoleId)
+		}
+	})
+}
+
+func roleNames(roles []c3po.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+func init() {
+
+	GroupCmd.AddCommand(groupRolesCmd)
+	groupRolesCmd.AddCommand(groupRolesListCmd, groupRolesAssignCmd, groupRolesUnassignCmd)
+
+}
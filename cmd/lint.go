@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pLintSkip []string
+var pLintSeverity, pLintFailOn string
+
+// LintCmd reports hygiene problems
+var LintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Report orphaned, empty and badly named roles, groups and functional abilities",
+	Long: `Check the whole application for hygiene problems and print one finding per line.
+Use -o json, csv or tsv for a machine-readable report, e.g. from a nightly job.
+
+Checks:
+` + lintCheckList() + `
+Exits with status 1 when there is a finding of --fail-on severity or worse
+(default warning; "none" never fails).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		severity, err := c3po.ParseLintSeverity(pLintSeverity)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		failOn := ""
+		if !strings.EqualFold(pLintFailOn, "none") {
+			if failOn, err = c3po.ParseLintSeverity(pLintFailOn); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		}
+
+		skip := make(map[string]bool)
+		for _, id := range pLintSkip {
+			known := false
+			for _, check := range c3po.LintChecks {
+				known = known || check.Id == id
+			}
+			if !known {
+				log.Fatalf("ERROR: unknown check %q", id)
+			}
+			skip[id] = true
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		var findings []c3po.LintFinding
+		failed := false
+		for _, finding := range sClient.Lint(state) {
+			if skip[finding.Check] || !c3po.LintAtLeast(finding.Severity, severity) {
+				continue
+			}
+			findings = append(findings, finding)
+			failed = failed || (failOn != "" && c3po.LintAtLeast(finding.Severity, failOn))
+		}
+
+		if len(findings) == 0 && c3po.TextOutput() {
+			fmt.Printf("No findings (%d roles, %d groups, %d functional abilities checked).\n", len(state.Roles), len(state.Groups), len(state.FunctionalAbilities))
+			return
+		}
+
+		if err := printLintFindings(findings); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+func lintCheckList() string {
+	var b strings.Builder
+	for _, check := range c3po.LintChecks {
+		fmt.Fprintf(&b, "  %-24s %-8s %s\n", check.Id, check.Severity, check.Description)
+	}
+	return b.String()
+}
+
+func printLintFindings(findings []c3po.LintFinding) error {
+	columns := []c3po.Column{
+		{Name: "Severity", Value: func(v interface{}) string { return v.(c3po.LintFinding).Severity }},
+		{Name: "Check", Value: func(v interface{}) string { return v.(c3po.LintFinding).Check }},
+		{Name: "Kind", Value: func(v interface{}) string { return v.(c3po.LintFinding).Kind }},
+		{Name: "Name", Value: func(v interface{}) string { return v.(c3po.LintFinding).Name }},
+		{Name: "Id", Value: func(v interface{}) string { return v.(c3po.LintFinding).Id }},
+		{Name: "Message", Value: func(v interface{}) string { return v.(c3po.LintFinding).Message }},
+	}
+
+	return c3po.PrintItems(findings, columns, func() {
+		counts := make(map[string]int)
+		for _, finding := range findings {
+			fmt.Printf("%-8s %-24s %s %q: %s\n", strings.ToUpper(finding.Severity), finding.Check, finding.Kind, finding.Name, finding.Message)
+			counts[finding.Severity]++
+		}
+		fmt.Printf("\n%d error(s), %d warning(s), %d info.\n", counts[c3po.LintError], counts[c3po.LintWarning], counts[c3po.LintInfo])
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(LintCmd)
+
+	LintCmd.Flags().StringSliceVarP(&pLintSkip, "skip", "", nil, "Check to skip (repeatable)")
+	LintCmd.Flags().StringVarP(&pLintSeverity, "severity", "", c3po.LintInfo, "Only report findings of this severity or worse: error, warning or info")
+	LintCmd.Flags().StringVarP(&pLintFailOn, "fail-on", "", c3po.LintWarning, "Exit with status 1 on findings of this severity or worse: error, warning, info or none")
+
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pMembersFile string
+
+// groupMembersCmd groups the membership sub-commands
+var groupMembersCmd = &cobra.Command{
+	Use:     "members",
+	Aliases: []string{"member"},
+	Short:   "List, add and remove the users of a C3PO group",
+}
+
+var groupMembersListCmd = &cobra.Command{
+	Use:   "list <group name>",
+	Short: "List the users of a group",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		group, err := sClient.LookupGroup(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		users, err := sClient.ListGroupMembers(group.Id)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if c3po.TextOutput() {
+			fmt.Printf("Group: %s (%d members)\n", group.Name, len(users))
+		}
+		if err := sClient.PrintUsers(users); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+var groupMembersAddCmd = &cobra.Command{
+	Use:   "add <group name> [HubID...]",
+	Short: "Add users to a group",
+	Long: `Add users to a group. HubIDs are taken from the arguments and/or from --file
+(one or more per line, '#' starts a comment; '-' reads standard input, which needs
+--yes and the --credentials file). Every user must exist and be active. Exits with
+a non-zero status if any user failed.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeGroupMembers(args, "add", sClient.AddGroupMembers)
+	},
+}
+
+var groupMembersRemoveCmd = &cobra.Command{
+	Use:   "remove <group name> [HubID...]",
+	Short: "Remove users from a group",
+	Long: `Remove users from a group. HubIDs are taken from the arguments and/or from
+--file (one or more per line, '#' starts a comment; '-' reads standard input, which
+needs --yes and the --credentials file). Exits with a non-zero status if any user
+failed.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		changeGroupMembers(args, "remove", sClient.RemoveGroupMembers)
+	},
+}
+
+func changeGroupMembers(args []string, verb string, change func(string, []string) ([]c3po.MemberResult, error)) {
+	hubids, err := readHubIDs(args[1:], pMembersFile)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if len(hubids) == 0 {
+		log.Fatalf("ERROR: no HubIDs given")
+	}
+	if pMembersFile == "-" && !pYes && !pDryRun {
+		log.Fatalf("ERROR: --yes is required when HubIDs are read from standard input")
+	}
+
+	group, err := sClient.LookupGroup(args[0])
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Users to %s (%s): %s\n", verb, group.Name, strings.Join(hubids, ", "))
+	if !pDryRun && !confirm(fmt.Sprintf("Proceed with %d user(s)?", len(hubids))) {
+		fmt.Fprintln(os.Stderr, "Aborted.")
+		return
+	}
+
+	results, err := change(group.Id, hubids)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	if err := sClient.PrintMemberResults(results); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d succeeded, %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// readHubIDs merges the HubIDs given as arguments with the ones read from file
+// ("-" for stdin), dropping blanks, comments and duplicates.
+func readHubIDs(args []string, file string) ([]string, error) {
+	var hubids []string
+	seen := make(map[string]bool)
+	add := func(hubid string) {
+		hubid = strings.TrimSpace(hubid)
+		key := strings.ToLower(hubid)
+		if hubid == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		hubids = append(hubids, hubid)
+	}
+
+	for _, arg := range args {
+		add(arg)
+	}
+
+	if file == "" {
+		return hubids, nil
+	}
+
+	var reader io.Reader = stdinReader
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ';' || r == ' ' || r == '\t' }) {
+			add(field)
+		}
+	}
+	return hubids, scanner.Err()
+}
+
+func init() {
+
+	GroupCmd.AddCommand(groupMembersCmd)
+	groupMembersCmd.AddCommand(groupMembersListCmd, groupMembersAddCmd, groupMembersRemoveCmd)
+
+	for _, c := range []*cobra.Command{groupMembersAddCmd, groupMembersRemoveCmd} {
+		c.Flags().StringVarP(&pMembersFile, "file", "f", "", "File with HubIDs ('-' for standard input)")
+	}
+
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pManifestFile string
+var pPrune, pPruneAll bool
+
+// PlanCmd shows what apply would change
+var PlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes needed to make C3PO match a manifest",
+	Long: `Compare a YAML/JSON access manifest with the current roles, groups, role bindings,
+attributes and members, and print the changes 'c3po apply' would make.
+
+Roles and groups missing from the manifest are left alone unless --prune is given.
+--prune only deletes the roles (groups) when the manifest has a roles (groups)
+section, and refuses to delete all of them when that section is empty unless
+--prune-all is given.
+Inside a group, a list that is omitted (roles, attributes, members) is not managed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		plan, _ := planManifest()
+		if len(plan.Changes) == 0 && c3po.TextOutput() {
+			fmt.Fprintln(os.Stderr, "No changes. C3PO matches the manifest.")
+			return
+		}
+		if err := sClient.PrintPlan(plan); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+// ApplyCmd applies a manifest
+var ApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Make C3PO match a manifest",
+	Long: `Compute the plan for a YAML/JSON access manifest (see 'c3po plan'), then create,
+update and delete roles, groups, role bindings, attributes and members in dependency
+order. Stops at the first failure and exits with a non-zero status.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		plan, state := planManifest()
+		if len(plan.Changes) == 0 {
+			fmt.Fprintln(os.Stderr, "No changes. C3PO matches the manifest.")
+			return
+		}
+
+		if err := sClient.PrintPlan(plan); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun && !confirm("Apply these changes?") {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return
+		}
+
+		applied, err := sClient.ApplyPlan(plan, state, func(change c3po.Change, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "FAILED  %s %s %q: %v\n", change.Action, change.Kind, change.Target, err)
+			} else if !pDryRun {
+				fmt.Fprintf(os.Stderr, "OK      %s %s %q\n", change.Action, change.Kind, change.Target)
+			}
+		})
+
+		created, updated, deleted := c3po.CountChanges(applied)
+		fmt.Fprintf(os.Stderr, "\nApply complete: %d created, %d updated, %d deleted", created, updated, deleted)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, ", %d not applied\n", len(plan.Changes)-len(applied))
+			log.Printf("ERROR: %v", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, ".")
+	},
+}
+
+func planManifest() (*c3po.Plan, *c3po.State) {
+	if pManifestFile == "" {
+		log.Fatalf("ERROR: --file is required")
+	}
+
+	manifest, err := c3po.LoadManifest(pManifestFile)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	state, err := sClient.FetchState()
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	if manifest.Application != "" && manifest.Application != state.ApplicationId {
+		log.Fatalf("ERROR: manifest is for application %s, not %s", manifest.Application, state.ApplicationId)
+	}
+
+	prune := c3po.PruneNone
+	if pPruneAll {
+		prune = c3po.PruneAll
+	} else if pPrune {
+		prune = c3po.PruneMissing
+	}
+
+	plan, err := sClient.PlanManifest(manifest, state, prune)
+	if errors.Is(err, c3po.ErrPruneEverything) {
+		log.Fatalf("ERROR: %v (use --prune-all if that is intended)", err)
+	} else if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	return plan, state
+}
+
+func init() {
+
+	RootCmd.AddCommand(PlanCmd, ApplyCmd)
+
+	for _, c := range []*cobra.Command{PlanCmd, ApplyCmd} {
+		c.Flags().StringVarP(&pManifestFile, "file", "f", "", "Access manifest (YAML or JSON file, or a directory written by 'c3po export --dir')")
+		c.Flags().BoolVarP(&pPrune, "prune", "", false, "Also delete roles and groups that are not in the manifest")
+		c.Flags().BoolVarP(&pPruneAll, "prune-all", "", false, "Like --prune, but also delete every role or group when the manifest's section is empty")
+	}
+
+}
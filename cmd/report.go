@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pReportSince string
+var pReportNoChanges bool
+
+// ReportCmd groups the report sub-commands
+var ReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Reports on the application's access",
+}
+
+var reportClassificationCmd = &cobra.Command{
+	Use:   "classification",
+	Short: "Show, per data classification level, the roles, groups and users exposed to it",
+	Long: `For every DataClassification level carried by a functional ability, highest
+first, list the abilities, the roles carrying them, the groups bound to those
+roles and how many users are exposed. The users of the highest level held by
+anyone are listed by name.
+
+Changes (abilities, roles, groups and users added to or removed from each level)
+are shown against the latest snapshot of the application, or the one given with
+--since (a file or a date, see 'c3po snapshot diff').
+
+Level names default to 0 Unclassified, 1 Public, 2 Internal, 3 Confidential and
+4 Restricted; ` + c3po.ClassificationLevelsFile + ` can rename them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := c3po.LoadClassificationLevels(c3po.ClassificationLevelsFile); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		var baseline *c3po.Snapshot
+		if !pReportNoChanges {
+			if pReportSince != "" {
+				baseline, err = c3po.ResolveSnapshot(pSnapshotDir, state.ApplicationId, pReportSince)
+			} else {
+				var snapshots []c3po.SnapshotInfo
+				snapshots, err = c3po.ListSnapshots(pSnapshotDir, state.ApplicationId)
+				if err == nil && len(snapshots) > 0 {
+					baseline, err = c3po.LoadSnapshot(snapshots[len(snapshots)-1].Path)
+				} else if err == nil {
+					fmt.Fprintf(os.Stderr, "No snapshot of %s in %s; changes are not shown.\n", state.ApplicationId, pSnapshotDir)
+				}
+			}
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		}
+		if baseline != nil && baseline.ApplicationId != state.ApplicationId {
+			log.Fatalf("ERROR: snapshot is for application %s, not %s", baseline.ApplicationId, state.ApplicationId)
+		}
+
+		var report []c3po.ClassificationExposure
+		if baseline != nil {
+			report = c3po.ClassificationReport(state, baseline.State, baseline.Taken)
+		} else {
+			report = c3po.ClassificationReport(state, nil, time.Time{})
+		}
+
+		if len(report) == 0 && c3po.TextOutput() {
+			fmt.Println("No functional abilities.")
+			return
+		}
+		if baseline != nil && c3po.TextOutput() {
+			fmt.Printf("Changes since snapshot taken %s\n\n", baseline.Taken.Format(time.RFC3339))
+		}
+
+		if err := printClassificationReport(report); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+func printClassificationReport(report []c3po.ClassificationExposure) error {
+	count := func(values []string) string { return fmt.Sprint(len(values)) }
+	columns := []c3po.Column{
+		{Name: "Level", Value: func(v interface{}) string { return fmt.Sprint(v.(c3po.ClassificationExposure).Level) }},
+		{Name: "Name", Value: func(v interface{}) string { return v.(c3po.ClassificationExposure).Name }},
+		{Name: "Highest", Value: func(v interface{}) string { return fmt.Sprint(v.(c3po.ClassificationExposure).Highest) }},
+		{Name: "Abilities", Value: func(v interface{}) string { return count(v.(c3po.ClassificationExposure).Abilities) }},
+		{Name: "Roles", Value: func(v interface{}) string { return count(v.(c3po.ClassificationExposure).Roles) }},
+		{Name: "Groups", Value: func(v interface{}) string { return count(v.(c3po.ClassificationExposure).Groups) }},
+		{Name: "Users", Value: func(v interface{}) string { return count(v.(c3po.ClassificationExposure).Users) }},
+		{Name: "UsersAdded", Value: func(v interface{}) string {
+			if changes := v.(c3po.ClassificationExposure).Changes; changes != nil {
+				return count(changes.UsersAdded)
+			}
+			return ""
+		}},
+		{Name: "UsersRemoved", Value: func(v interface{}) string {
+			if changes := v.(c3po.ClassificationExposure).Changes; changes != nil {
+				return count(changes.UsersRemoved)
+			}
+			return ""
+		}},
+	}
+
+	return c3po.PrintItems(report, columns, func() {
+		for i, level := range report {
+			if i > 0 {
+				fmt.Println()
+			}
+			heading := fmt.Sprintf("%s (%d)", level.Name, level.Level)
+			if level.Highest {
+				heading += "  ** HIGHEST LEVEL HELD **"
+			}
+			fmt.Println(heading)
+			fmt.Printf("  %d abilities, %d roles, %d groups, %d users exposed\n", len(level.Abilities), len(level.Roles), len(level.Groups), len(level.Users))
+			printList("Abilities", level.Abilities)
+			printList("Roles", level.Roles)
+			printList("Groups", level.Groups)
+			if level.Highest {
+				printList("Users", level.Users)
+			}
+
+			if level.Changes == nil {
+				continue
+			}
+			if level.Changes.Empty() {
+				fmt.Println("  No changes.")
+				continue
+			}
+			changes := []struct {
+				symbol string
+				kind   string
+				names  []string
+			}{
+				{"+", "ability", level.Changes.AbilitiesAdded},
+				{"-", "ability", level.Changes.AbilitiesRemoved},
+				{"+", "role", level.Changes.RolesAdded},
+				{"-", "role", level.Changes.RolesRemoved},
+				{"+", "group", level.Changes.GroupsAdded},
+				{"-", "group", level.Changes.GroupsRemoved},
+				{"+", "user", level.Changes.UsersAdded},
+				{"-", "user", level.Changes.UsersRemoved},
+			}
+			for _, change := range changes {
+				for _, name := range change.names {
+					fmt.Printf("  %s %s %q\n", change.symbol, change.kind, name)
+				}
+			}
+		}
+	})
+}
+
+func printList(label string, values []string) {
+	if len(values) > 0 {
+		fmt.Printf("  %-10s %s\n", label+":", strings.Join(values, ", "))
+	}
+}
+
+func init() {
+
+	RootCmd.AddCommand(ReportCmd)
+	ReportCmd.AddCommand(reportClassificationCmd)
+
+	reportClassificationCmd.Flags().StringVarP(&pReportSince, "since", "", "", "Snapshot (file or date) to compare with (default the latest)")
+	reportClassificationCmd.Flags().StringVarP(&pSnapshotDir, "snapshot-dir", "", c3po.SnapshotDir, "Snapshot directory")
+	reportClassificationCmd.Flags().BoolVarP(&pReportNoChanges, "no-changes", "", false, "Do not compare with a snapshot")
+
+}
@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pReviewDir, pReviewFormat, pReviewAttribute, pReviewReport, pReviewKeyFile string
+
+// ReviewCmd groups the access recertification sub-commands
+var ReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Access recertification: worksheets for approval managers and applying their decisions",
+	Long: `Run an access review in three steps:
+
+  c3po review start                       write one worksheet per approval manager
+  (managers fill in keep or revoke in the Decision column of each row)
+  c3po review apply review-jdoe.csv       remove revoked memberships, write a signed report
+  c3po review verify review-jdoe-report.json
+
+A group's approval manager is the value of its ` + c3po.ReviewManagerAttribute + ` group attribute
+(see --manager-attribute); groups without one go to the "` + c3po.ReviewUnassigned + `" worksheet.`,
+}
+
+var reviewStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Write a worksheet of group memberships for each approval manager",
+	Long: `Export every group membership with the user, group, roles, functional abilities,
+highest DataClassification and the group's last update to a CSV (or JSON)
+worksheet per approval manager, with empty Decision and Comment columns for the
+reviewer. Existing worksheets are never overwritten.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := strings.ToLower(pReviewFormat)
+		if format != "csv" && format != "json" {
+			log.Fatalf("ERROR: invalid format %q (expected csv or json)", pReviewFormat)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		dir := pReviewDir
+		if dir == "" {
+			dir = fmt.Sprintf("review-%s-%s", state.ApplicationId, time.Now().UTC().Format("20060102"))
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		worksheets := c3po.BuildReview(state, pReviewAttribute)
+		names := c3po.ReviewWorksheetNames(worksheets, format)
+		var written []reviewWorksheetFile
+		for i, worksheet := range worksheets {
+			path := filepath.Join(dir, names[i])
+			if err := c3po.WriteReviewWorksheet(path, worksheet); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			written = append(written, reviewWorksheetFile{Reviewer: worksheet.Reviewer, Path: path, Memberships: len(worksheet.Items)})
+		}
+
+		if c3po.TextOutput() {
+			if len(written) == 0 {
+				fmt.Println("No group memberships to review.")
+				return
+			}
+			fmt.Printf("Wrote %d worksheet(s) to %s:\n\n", len(written), dir)
+		}
+		if err := printReviewWorksheetFiles(written); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+var reviewApplyCmd = &cobra.Command{
+	Use:   "apply <worksheet>",
+	Short: "Remove the memberships revoked in a completed worksheet and write a signed report",
+	Long: `Read a worksheet completed by an approval manager and check it against the current
+state: every row needs a keep or revoke decision, and every group must still be
+assigned to the worksheet's reviewer (see --manager-attribute, which must match
+the one given to 'review start'). Memberships that no longer exist are skipped.
+After confirmation the revoked memberships are removed.
+
+A report of every row's outcome, with the worksheet's SHA-256, is written as JSON
+(--report, default <worksheet>-report.json) and signed with an HMAC-SHA256 key
+(--key-file, default ` + c3po.ReviewKeyFile + `, created on first use). Check it
+with 'c3po review verify'. Under --dry-run a report, marked as a dry run, is only
+written to an explicit --report.
+
+Exits with status 1 when a revocation failed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		items, err := c3po.ReadReviewWorksheet(data, path)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		outcomes, err := c3po.PlanReview(state, items, pReviewAttribute)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		var revoke, kept, skipped int
+		for _, outcome := range outcomes {
+			switch outcome.Status {
+			case "":
+				revoke++
+				fmt.Fprintf(os.Stderr, "REVOKE  %s (%s) from %q\n", outcome.HubID, outcome.CommonName, outcome.Group)
+			case c3po.ReviewKept:
+				kept++
+			case c3po.ReviewSkipped:
+				skipped++
+				fmt.Fprintf(os.Stderr, "SKIP    %s in %q: %s\n", outcome.HubID, outcome.Group, outcome.Reason)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "\n%d to revoke, %d kept, %d skipped.\n", revoke, kept, skipped)
+
+		if revoke > 0 && !pDryRun && !confirm(fmt.Sprintf("Revoke %d membership(s)?", revoke)) {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return
+		}
+
+		outcomes = sClient.ApplyReview(outcomes, func(outcome c3po.ReviewOutcome) {
+			if outcome.Status == c3po.ReviewFailed {
+				fmt.Fprintf(os.Stderr, "FAILED  %s from %q: %s\n", outcome.HubID, outcome.Group, outcome.Reason)
+			} else if !pDryRun {
+				fmt.Fprintf(os.Stderr, "OK      %s from %q\n", outcome.HubID, outcome.Group)
+			}
+		})
+
+		// A dry run must not replace the report of the real one: it only writes
+		// one where --report says.
+		if pDryRun && pReviewReport == "" {
+			fmt.Fprintf(os.Stderr, "\nDry run: %d would be revoked, %d kept, %d skipped, %d failed. No report written (use --report).\n",
+				countReview(outcomes, c3po.ReviewWouldRevoke), kept, skipped, countReview(outcomes, c3po.ReviewFailed))
+			return
+		}
+
+		key, created, err := c3po.LoadReviewKey(pReviewKeyFile, true)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if created {
+			fmt.Fprintf(os.Stderr, "Created signing key %s\n", pReviewKeyFile)
+		}
+
+		report := sClient.NewReviewReport(path, data, outcomes)
+		if err := report.Sign(key); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		reportPath := pReviewReport
+		if reportPath == "" {
+			reportPath = strings.TrimSuffix(path, filepath.Ext(path)) + "-report.json"
+		}
+		if err := c3po.WriteReviewReport(reportPath, report); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if report.DryRun {
+			fmt.Fprintf(os.Stderr, "\nDry run: %d would be revoked, %d kept, %d skipped, %d failed. Signed DRY RUN report written to %s\n",
+				report.Counts[c3po.ReviewWouldRevoke], report.Counts[c3po.ReviewKept], report.Counts[c3po.ReviewSkipped], report.Counts[c3po.ReviewFailed], reportPath)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\nReview applied: %d revoked, %d kept, %d skipped, %d failed. Signed report written to %s\n",
+			report.Counts[c3po.ReviewRevoked], report.Counts[c3po.ReviewKept], report.Counts[c3po.ReviewSkipped], report.Counts[c3po.ReviewFailed], reportPath)
+		if report.Counts[c3po.ReviewFailed] > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var reviewVerifyCmd = &cobra.Command{
+	Use:         "verify <report>",
+	Short:       "Check the signature of a review report",
+	Args:        cobra.ExactArgs(1),
+	Annotations: offline,
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := c3po.ReadReviewReport(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		key, _, err := c3po.LoadReviewKey(pReviewKeyFile, false)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		ok, err := report.Verify(key)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !ok {
+			fmt.Printf("%s: signature does NOT match; the report was modified or signed with another key.\n", args[0])
+			os.Exit(1)
+		}
+		if report.DryRun {
+			fmt.Printf("%s: signature OK. DRY RUN: worksheet %s (sha256 %s) was NOT applied to %s (%s); tried by %s on %s.\n",
+				args[0], report.Worksheet, report.WorksheetChecksum, report.ApplicationId, report.Environment, report.AppliedBy, report.Applied.Format(time.RFC3339))
+			return
+		}
+		fmt.Printf("%s: signature OK. Worksheet %s (sha256 %s) applied to %s (%s) by %s on %s.\n",
+			args[0], report.Worksheet, report.WorksheetChecksum, report.ApplicationId, report.Environment, report.AppliedBy, report.Applied.Format(time.RFC3339))
+	},
+}
+
+// countReview returns how many outcomes have the status.
+func countReview(outcomes []c3po.ReviewOutcome, status string) int {
+	n := 0
+	for _, outcome := range outcomes {
+		if outcome.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// reviewWorksheetFile is a worksheet written by 'review start'.
+type reviewWorksheetFile struct {
+	Reviewer    string
+	Path        string
+	Memberships int
+}
+
+func printReviewWorksheetFiles(files []reviewWorksheetFile) error {
+	columns := []c3po.Column{
+		{Name: "Reviewer", Value: func(v interface{}) string { return v.(reviewWorksheetFile).Reviewer }},
+		{Name: "Path", Value: func(v interface{}) string { return v.(reviewWorksheetFile).Path }},
+		{Name: "Memberships", Value: func(v interface{}) string { return fmt.Sprint(v.(reviewWorksheetFile).Memberships) }},
+	}
+
+	return c3po.PrintItems(files, columns, func() {
+		for _, file := range files {
+			fmt.Printf("  %-30s %5d  %s\n", file.Reviewer, file.Memberships, file.Path)
+		}
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(ReviewCmd)
+	ReviewCmd.AddCommand(reviewStartCmd, reviewApplyCmd, reviewVerifyCmd)
+
+	reviewStartCmd.Flags().StringVarP(&pReviewDir, "dir", "", "", "Directory for the worksheets (default review-<application>-<date>)")
+	reviewStartCmd.Flags().StringVarP(&pReviewFormat, "format", "", "csv", "Worksheet format: csv or json")
+	for _, c := range []*cobra.Command{reviewStartCmd, reviewApplyCmd} {
+		c.Flags().StringVarP(&pReviewAttribute, "manager-attribute", "", c3po.ReviewManagerAttribute, "Group attribute naming the group's approval manager")
+	}
+
+	reviewApplyCmd.Flags().StringVarP(&pReviewReport, "report", "", "", "Where to write the signed report (default <worksheet>-report.json)")
+	for _, c := range []*cobra.Command{reviewApplyCmd, reviewVerifyCmd} {
+		c.Flags().StringVarP(&pReviewKeyFile, "key-file", "", c3po.ReviewKeyFile, "HMAC key the report is signed with")
+	}
+
+}
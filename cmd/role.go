@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pDescription, pExpression, pNewName string
+var pAbilities, pAddAbilities, pRemoveAbilities []string
+
+// RoleCmd groups the role sub-commands
+var RoleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "Create, update and delete C3PO roles",
+}
+
+var roleCreateCmd = &cobra.Command{
+	Use:   "create <role name>",
+	Short: "Create a role",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		abilities, err := sClient.ResolveFunctionalAbilities(pAbilities)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		role := c3po.Role{
+			Name:                    args[0],
+			Description:             pDescription,
+			RoleFunctionalAbilities: c3po.RoleAbilities(abilityIds(abilities)),
+		}
+		if pExpression != "" {
+			role.ConditionalExpression = pExpression
+		}
+
+		if err := sClient.ValidateRole(role); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if _, err := sClient.LookupRole(role.Name); err == nil {
+			log.Fatalf("ERROR: role %q already exists", role.Name)
+		}
+
+		fmt.Println("Role to create:")
+		printRolePreview(role, abilityNames(abilities))
+		if !pDryRun && !confirm("Create role "+role.Name+"?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		created, err := sClient.CreateRole(role)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun {
+			fmt.Printf("Created role %s (%s)\n", created.Name, created.Id)
+		}
+	},
+}
+
+var roleUpdateCmd = &cobra.Command{
+	Use:   "update <role name>",
+	Short: "Update a role's name, description, conditional expression or functional abilities",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, err := sClient.LookupRole(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		all, err := sClient.ListFunctionalAbilities()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		names := make(map[string]string)
+		for _, ability := range all {
+			names[ability.Id] = ability.Name
+		}
+
+		current := make(map[string]bool)
+		for _, id := range c3po.RoleAbilityIds(role) {
+			current[id] = true
+		}
+
+		var before []string
+		for id := range current {
+			before = append(before, names[id])
+		}
+		sort.Strings(before)
+		fmt.Println("Current role:")
+		printRolePreview(role, before)
+
+		if cmd.Flags().Changed("new-name") {
+			role.Name = pNewName
+		}
+		if cmd.Flags().Changed("description") {
+			role.Description = pDescription
+		}
+		if cmd.Flags().Changed("expression") {
+			if pExpression == "" {
+				role.ConditionalExpression = nil
+			} else {
+				role.ConditionalExpression = pExpression
+			}
+		}
+
+		if cmd.Flags().Changed("ability") || len(pAddAbilities) > 0 || len(pRemoveAbilities) > 0 {
+			if cmd.Flags().Changed("ability") {
+				current = make(map[string]bool)
+			}
+
+			add, err := sClient.ResolveFunctionalAbilities(append(append([]string{}, pAbilities...), pAddAbilities...))
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			for _, ability := range add {
+				current[ability.Id] = true
+			}
+
+			remove, err := sClient.ResolveFunctionalAbilities(pRemoveAbilities)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			for _, ability := range remove {
+				delete(current, ability.Id)
+			}
+
+			var ids []string
+			for id := range current {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			role.RoleFunctionalAbilities = c3po.RoleAbilities(ids)
+		}
+
+		var after []string
+		for id := range current {
+			after = append(after, names[id])
+		}
+		sort.Strings(after)
+
+		// Only what changes is validated, so that a role whose name or
+		// expression predates the conventions can still be edited.
+		if cmd.Flags().Changed("new-name") {
+			if err := sClient.ValidateRoleName(role.Name); err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+		}
+		if cmd.Flags().Changed("expression") {
+			if err := c3po.ValidateExpression(role.ConditionalExpression); err != nil {
+				log.Fatalf("ERROR: role %q: %v", role.Name, err)
+			}
+		}
+		fmt.Println("Updated role:")
+		printRolePreview(role, after)
+
+		if !pDryRun && !confirm("Update role "+args[0]+"?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		if err := sClient.UpdateRole(role); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun {
+			fmt.Printf("Updated role %s (%s)\n", role.Name, role.Id)
+		}
+	},
+}
+
+var roleDeleteCmd = &cobra.Command{
+	Use:   "delete <role name>",
+	Short: "Delete a role",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, err := sClient.LookupRole(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		fmt.Println("Role to delete:")
+		printRolePreview(role, nil)
+		if !pDryRun && !confirm("Delete role "+role.Name+"?") {
+			fmt.Println("Aborted.")
+			return
+		}
+
+		if err := sClient.DeleteRole(role.Id); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if !pDryRun {
+			fmt.Printf("Deleted role %s (%s)\n", role.Name, role.Id)
+		}
+	},
+}
+
+func printRolePreview(role c3po.Role, abilities []string) {
+	fmt.Println("\tName:", role.Name)
+	fmt.Println("\tDescription:", role.Description)
+	if role.ConditionalExpression != nil {
+		fmt.Println("\tConditionalExpression:", c3po.FormatExpression(role.ConditionalExpression))
+	}
+	if abilities != nil {
+		fmt.Println("\tFunctionalAbilities:", strings.Join(abilities, ", "))
+	}
+}
+
+func abilityIds(abilities []c3po.FunctionalAbilities) []string {
+	ids := make([]string, 0, len(abilities))
+	for _, ability := range abilities {
+		ids = append(ids, ability.Id)
+	}
+	return ids
+}
+
+func abilityNames(abilities []c3po.FunctionalAbilities) []string {
+	names := make([]string, 0, len(abilities))
+	for _, ability := range abilities {
+		names = append(names, ability.Name)
+	}
+	return names
+}
+
+func init() {
+
+	RootCmd.AddCommand(RoleCmd)
+	RoleCmd.AddCommand(roleCreateCmd, roleUpdateCmd, roleDeleteCmd)
+
+	for _, c := range []*cobra.Command{roleCreateCmd, roleUpdateCmd} {
+		c.Flags().StringVarP(&pDescription, "description", "", "", "Role description")
+		c.Flags().StringVarP(&pExpression, "expression", "", "", "Conditional expression for dynamic assignment (empty to clear on update)")
+		c.Flags().StringSliceVarP(&pAbilities, "ability", "a", nil, "Functional ability name to assign (repeatable; replaces the current set on update)")
+	}
+	roleUpdateCmd.Flags().StringVarP(&pNewName, "new-name", "", "", "Rename the role")
+	roleUpdateCmd.Flags().StringSliceVarP(&pAddAbilities, "add-ability", "", nil, "Functional ability name to add (repeatable)")
+	roleUpdateCmd.Flags().StringSliceVarP(&pRemoveAbilities, "remove-ability", "", nil, "Functional ability name to remove (repeatable)")
+
+}
@@ -14,8 +14,22 @@ import (
 )
 
 var sClient *c3po.Client
-var version, c3poAccessToken string
-var debug bool
+var version, c3poAccessToken, pEnvironment string
+var c3poUsername, c3poPassword string
+var debug, pYes, pDryRun bool
+var pOutput, pQuery string
+var pFields []string
+var pNoHeaders bool
+var pCredentials string
+
+// stdinReader is shared by the credential and confirmation prompts.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// offlineAnnotation marks commands that work on local files only and must not
+// ask for credentials. They can still call initConfig when they need Keystone.
+const offlineAnnotation = "offline"
+
+var offline = map[string]string{offlineAnnotation: "true"}
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -23,6 +37,17 @@ var RootCmd = &cobra.Command{
 	Version: version,
 	Short:   "test code",
 	Long:    `This is a test code for Sam's learning`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		output, err := c3po.ParseOutput(pOutput, pFields, pNoHeaders, pQuery)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		c3po.SetOutput(output)
+
+		if cmd.Annotations[offlineAnnotation] == "" {
+			initConfig()
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 
 	},
@@ -36,46 +61,111 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
 	RootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "To turn-on debugging")
+	RootCmd.PersistentFlags().BoolVarP(&pYes, "yes", "y", false, "Do not ask for confirmation before changing anything")
+	RootCmd.PersistentFlags().BoolVarP(&pDryRun, "dry-run", "", false, "Print the requests that would change C3PO instead of sending them")
+	RootCmd.PersistentFlags().StringVarP(&pEnvironment, "env", "e", "prod", "Keystone environment (prod, or one declared in "+c3po.EnvironmentsFile+")")
+	RootCmd.PersistentFlags().StringVarP(&pOutput, "output", "o", c3po.FormatText, "Output format: text, table, json, ndjson, yaml, csv, tsv or template='{{.Name}}'")
+	RootCmd.PersistentFlags().StringSliceVarP(&pFields, "fields", "", nil, "Comma-separated fields (columns) to print, in order")
+	RootCmd.PersistentFlags().StringVarP(&pQuery, "query", "q", "", "jq-style query applied to the result before printing, e.g. '.[] | select(.SodRole != \"\") | .Name'")
+	RootCmd.PersistentFlags().BoolVarP(&pNoHeaders, "no-headers", "", false, "Do not print the header line of table, csv and tsv output")
+	RootCmd.PersistentFlags().StringVarP(&pCredentials, "credentials", "", c3po.CredentialsFile, "YAML file with the hubid and password to log in with instead of prompting (cron, automation)")
+}
+
+// confirm asks a yes/no question and reports whether the user agreed.
+// It always agrees with --yes.
+func confirm(question string) bool {
+	if pYes {
+		return true
+	}
+
+	fmt.Fprint(os.Stderr, question+" [y/N] ")
+	answer, _ := stdinReader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
-// initConfig reads in config file and ENV variables if set.
+// initConfig reads in config file and ENV variables if set, and logs in.
+// It runs once before every command that is not marked offline.
 func initConfig() {
+	if sClient != nil {
+		return
+	}
+
 	strMyOS := "Linux"
 	if runtime.GOOS == "windows" {
 		strMyOS = "Windows"
 	}
 
-	fmt.Println("My OS : ", strMyOS, "\n")
+	// Status goes to stderr so that stdout only holds the command's results.
+	fmt.Fprintln(os.Stderr, "My OS : ", strMyOS)
+	fmt.Fprintln(os.Stderr)
 
-        reader := bufio.NewReader(os.Stdin)
+	sClient = loginEnvironment(pEnvironment)
+}
 
-        fmt.Print("*** Enter Your HubID : ")
-        username, _ := reader.ReadString('\n')
-        username = strings.TrimSpace(username) // Remove any trailing newline characters
+// credentials returns the HubID and password, read from the --credentials
+// file or else prompted for, the first time.
+func credentials() (string, string) {
+	if c3poUsername != "" && c3poPassword != "" {
+		return c3poUsername, c3poPassword
+	}
 
-        fmt.Print("*** Enter Password: ")
-        bytePassword, _ := term.ReadPassword(int(os.Stdin.Fd()))
-        password := string(bytePassword)
-	fmt.Println("")
+	saved, ok, err := c3po.LoadCredentials(pCredentials)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if ok {
+		c3poUsername, c3poPassword = saved.HubID, saved.Password
+		return c3poUsername, c3poPassword
+	}
+	if pMembersFile == "-" {
+		// Standard input carries the HubIDs: the first one must not become the login.
+		log.Fatalf("ERROR: --file - reads HubIDs from standard input, so the login must come from %s", pCredentials)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		log.Fatalf("ERROR: cannot prompt for the HubID and password: standard input is not a terminal (put them in %s)", pCredentials)
+	}
 
+	fmt.Fprint(os.Stderr, "*** Enter Your HubID : ")
+	username, _ := stdinReader.ReadString('\n')
+	username = strings.TrimSpace(username) // Remove any trailing newline characters
+
+	fmt.Fprint(os.Stderr, "*** Enter Password: ")
+	bytePassword, _ := term.ReadPassword(int(os.Stdin.Fd()))
+	password := string(bytePassword)
+	fmt.Fprintln(os.Stderr)
+
+	c3poUsername, c3poPassword = username, password
+	return username, password
+}
+
+// loginEnvironment creates a client for the named Keystone environment and
+// authenticates it with the user's credentials.
+func loginEnvironment(name string) *c3po.Client {
+	env, err := c3po.LookupEnvironment(c3po.EnvironmentsFile, name)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+
+	username, password := credentials()
 	token := ""
 
-	var err error
-	sClient, err = c3po.NewClient(username, password, token, debug)
+	client, err := c3po.NewClientForEnvironment(env, username, password, token, debug)
 	if err != nil {
 		log.Fatalf("ERROR: Can't create C3PO client: %v", err)
 	}
-	if sClient == nil {
-		log.Fatalf("ERROR: sClient is nil after NewClient call")
+	if client == nil {
+		log.Fatalf("ERROR: client is nil after NewClient call")
 	}
+	client.SetDryRun(pDryRun)
 
-	c3poAccessToken, err = sClient.GetAccessToken()
+	c3poAccessToken, err = client.GetAccessToken()
 	if err != nil {
-		log.Fatalf("ERROR: %v", err)
+		log.Fatalf("ERROR: %s: %v", env.Name, err)
 	}
 
-	fmt.Println("Access Token : ", c3poAccessToken)
+	fmt.Fprintln(os.Stderr, "Access Token ("+env.Name+") : ", c3poAccessToken)
 
+	return client
 }
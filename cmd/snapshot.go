@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pSnapshotDir, pSnapshotAt string
+
+// SnapshotCmd groups the snapshot sub-commands
+var SnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save point-in-time snapshots of C3PO and compare them",
+}
+
+var snapshotSaveCmd = &cobra.Command{
+	Use:   "save",
+	Short: "Save a compressed snapshot of every role, group, membership and ability",
+	Run: func(cmd *cobra.Command, args []string) {
+		snapshot, err := sClient.TakeSnapshot()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		path, err := c3po.SaveSnapshot(pSnapshotDir, snapshot)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		fmt.Printf("Saved snapshot of %d roles, %d groups and %d functional abilities to %s\n",
+			len(snapshot.State.Roles), len(snapshot.State.Groups), len(snapshot.State.FunctionalAbilities), path)
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List saved snapshots",
+	Annotations: offline,
+	Run: func(cmd *cobra.Command, args []string) {
+		snapshots, err := c3po.ListSnapshots(pSnapshotDir, "")
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if len(snapshots) == 0 && c3po.TextOutput() {
+			fmt.Println("No snapshots in", pSnapshotDir)
+			return
+		}
+
+		columns := []c3po.Column{
+			{Name: "Taken", Value: func(v interface{}) string { return v.(c3po.SnapshotInfo).Taken.Format(time.RFC3339) }},
+			{Name: "ApplicationId", Value: func(v interface{}) string { return v.(c3po.SnapshotInfo).ApplicationId }},
+			{Name: "Path", Value: func(v interface{}) string { return v.(c3po.SnapshotInfo).Path }},
+		}
+		err = c3po.PrintItems(snapshots, columns, func() {
+			for _, snapshot := range snapshots {
+				fmt.Printf("%s\t%s\t%s\n", snapshot.Taken.Format(time.RFC3339), snapshot.ApplicationId, snapshot.Path)
+			}
+		})
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <a> [b]",
+	Short: "Show what changed between two snapshots (or a snapshot and now)",
+	Long: `Show what changed between two snapshots: roles, functional abilities, groups,
+role bindings, attributes and members that were added, removed or changed, with
+their LastUpdate. Each snapshot is given as a file or as a date (YYYY-MM-DD or
+RFC 3339), meaning the latest snapshot of the --env application taken on or
+before it. Without b, the snapshot is compared with the live state.`,
+	Args:        cobra.RangeArgs(1, 2),
+	Annotations: offline,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Dates refer to the snapshots of the --env application; the live
+		// state is that of the application logged in to.
+		applicationId := ""
+		if len(args) == 2 {
+			applicationId = snapshotApplicationID()
+		} else {
+			initConfig()
+			applicationId = sClient.ApplicationID()
+		}
+
+		before, err := c3po.ResolveSnapshot(pSnapshotDir, applicationId, args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		var after *c3po.Snapshot
+		if len(args) == 2 {
+			after, err = c3po.ResolveSnapshot(pSnapshotDir, before.ApplicationId, args[1])
+		} else {
+			after, err = sClient.TakeSnapshot()
+		}
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if before.ApplicationId != after.ApplicationId {
+			log.Fatalf("ERROR: snapshots are for different applications (%s, %s)", before.ApplicationId, after.ApplicationId)
+		}
+
+		diffs := c3po.DiffStates(before.State, after.State)
+		if c3po.TextOutput() {
+			fmt.Printf("Changes from %s to %s:\n", before.Taken.Format(time.RFC3339), after.Taken.Format(time.RFC3339))
+			if len(diffs) == 0 {
+				fmt.Println("  No changes.")
+				return
+			}
+		}
+		if err := c3po.PrintDifferences(diffs); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+var snapshotWhoCmd = &cobra.Command{
+	Use:   "who --at <date> [--userid HUBID | --group NAME]",
+	Short: "Show who had access on a given date",
+	Long: `Use the latest snapshot of the --env application taken on or before --at to show
+the members of --group, the groups (and roles) of --userid, or every membership
+when neither is given.`,
+	Annotations: offline,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pSnapshotAt == "" {
+			log.Fatalf("ERROR: --at is required")
+		}
+
+		snapshot, err := c3po.ResolveSnapshot(pSnapshotDir, snapshotApplicationID(), pSnapshotAt)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if c3po.TextOutput() {
+			fmt.Printf("Snapshot taken %s\n", snapshot.Taken.Format(time.RFC3339))
+		}
+
+		groups := snapshot.State.Groups
+		if pGroupName != "" {
+			group, ok := snapshot.State.GroupByName(pGroupName)
+			if !ok {
+				log.Fatalf("ERROR: group %q is not in the snapshot", pGroupName)
+			}
+			groups = []c3po.GroupState{group}
+		}
+
+		var memberships []membership
+		for _, group := range groups {
+			var roles []string
+			for _, binding := range group.Roles {
+				if role, ok := snapshot.State.Role(binding.RoleId); ok {
+					roles = append(roles, role.Name)
+				}
+			}
+
+			for _, user := range group.Members {
+				if pUserID != "" && !strings.EqualFold(user.IdAtSourceSystem, pUserID) {
+					continue
+				}
+				memberships = append(memberships, membership{HubID: user.IdAtSourceSystem, CommonName: user.CommonName, Group: group.Group.Name, Roles: roles})
+			}
+		}
+
+		if err := printMemberships(memberships); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+// snapshotApplicationID returns the id of the --env application, read from the
+// environments file so that offline commands need not log in.
+func snapshotApplicationID() string {
+	env, err := c3po.LookupEnvironment(c3po.EnvironmentsFile, pEnvironment)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	return env.ApplicationID
+}
+
+// membership is one user's membership of a group, with the group's roles.
+type membership struct {
+	HubID      string
+	CommonName string
+	Group      string
+	Roles      []string
+}
+
+func printMemberships(memberships []membership) error {
+	columns := []c3po.Column{
+		{Name: "HubID", Value: func(v interface{}) string { return v.(membership).HubID }},
+		{Name: "CommonName", Value: func(v interface{}) string { return v.(membership).CommonName }},
+		{Name: "Group", Value: func(v interface{}) string { return v.(membership).Group }},
+		{Name: "Roles", Value: func(v interface{}) string { return strings.Join(v.(membership).Roles, ",") }},
+	}
+
+	return c3po.PrintItems(memberships, columns, func() {
+		for _, m := range memberships {
+			fmt.Printf("%s\t%s\t%s\t[%s]\n", m.HubID, m.CommonName, m.Group, strings.Join(m.Roles, ", "))
+		}
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(SnapshotCmd)
+	SnapshotCmd.AddCommand(snapshotSaveCmd, snapshotListCmd, snapshotDiffCmd, snapshotWhoCmd)
+
+	SnapshotCmd.PersistentFlags().StringVarP(&pSnapshotDir, "dir", "", c3po.SnapshotDir, "Snapshot directory")
+	snapshotWhoCmd.Flags().StringVarP(&pSnapshotAt, "at", "", "", "Date (YYYY-MM-DD or RFC 3339)")
+	snapshotWhoCmd.Flags().StringVarP(&pUserID, "userid", "u", "", "HUBID")
+	snapshotWhoCmd.Flags().StringVarP(&pGroupName, "group", "g", "", "Group Name. 'C3PO - ' is optional")
+
+}
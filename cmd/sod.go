@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pSodRulesFile string
+
+// SodCmd groups the segregation-of-duties sub-commands
+var SodCmd = &cobra.Command{
+	Use:   "sod",
+	Short: "Segregation-of-duties checks based on the SodRole of functional abilities",
+}
+
+var sodCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Report users and roles holding incompatible SodRoles",
+	Long: `Read the segregation-of-duties rules (sets of SodRoles that must not be held
+together) and check every role definition, and every user through their groups,
+roles and functional abilities. Each violation is printed with the access paths
+that produced it.
+
+Exits with status 1 when there is any violation, so it can gate a CI pipeline.
+
+Rules file (default ` + c3po.SodRulesFile + `):
+
+  rules:
+    - name: request-approve
+      description: Nobody may both request and approve access
+      sodRoles: [Requester, Approver]`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rules, err := c3po.LoadSodRules(pSodRulesFile)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		for _, sodRole := range c3po.UnknownSodRoles(state, rules) {
+			fmt.Fprintf(os.Stderr, "WARNING: no functional ability has SodRole %q\n", sodRole)
+		}
+
+		var violations []c3po.SodViolation
+		for _, violation := range c3po.CheckSod(state, rules) {
+			if pUserID != "" && (violation.Subject != c3po.SodSubjectUser || !strings.EqualFold(violation.Name, pUserID)) {
+				continue
+			}
+			violations = append(violations, violation)
+		}
+
+		if len(violations) == 0 && c3po.TextOutput() {
+			fmt.Printf("No SoD violations (%d rules, %d roles, %d groups checked).\n", len(rules), len(state.Roles), len(state.Groups))
+			return
+		}
+
+		if err := printSodViolations(violations); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if len(violations) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func printSodViolations(violations []c3po.SodViolation) error {
+	columns := []c3po.Column{
+		{Name: "Rule", Value: func(v interface{}) string { return v.(c3po.SodViolation).Rule }},
+		{Name: "Subject", Value: func(v interface{}) string { return v.(c3po.SodViolation).Subject }},
+		{Name: "Name", Value: func(v interface{}) string { return v.(c3po.SodViolation).Name }},
+		{Name: "SodRoles", Value: func(v interface{}) string { return strings.Join(v.(c3po.SodViolation).SodRoles, ",") }},
+		{Name: "Paths", Value: func(v interface{}) string { return strings.Join(v.(c3po.SodViolation).Paths, "; ") }},
+	}
+
+	return c3po.PrintItems(violations, columns, func() {
+		for _, violation := range violations {
+			fmt.Printf("VIOLATION %s: %s %s holds %s\n", violation.Rule, violation.Subject, violation.Name, strings.Join(violation.SodRoles, " + "))
+			for _, path := range violation.Paths {
+				fmt.Println("        " + path)
+			}
+		}
+		fmt.Printf("\n%d SoD violation(s).\n", len(violations))
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(SodCmd)
+	SodCmd.AddCommand(sodCheckCmd)
+
+	sodCheckCmd.Flags().StringVarP(&pSodRulesFile, "rules", "", c3po.SodRulesFile, "SoD rules file (YAML)")
+	sodCheckCmd.Flags().StringVarP(&pUserID, "userid", "u", "", "Only report this HubID")
+
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pCopyFrom, pCopyTo string
+var pCopyMove bool
+
+// UserCmd groups the user sub-commands
+var UserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Compare, copy and move the C3PO group memberships of users",
+}
+
+var userCompareCmd = &cobra.Command{
+	Use:   "compare <HubID> <HubID>",
+	Short: "Show the groups only one of two users is in, and the ones both are in",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		a, err := sClient.LookupUser(args[0])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		b, err := sClient.LookupUser(args[1])
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if err := printUserComparison(state.CompareUsers(a, b)); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+var userCopyAccessCmd = &cobra.Command{
+	Use:   "copy-access --from HUBID --to HUBID [--move]",
+	Short: "Add a user to every group another user is in; with --move, remove the other user",
+	Long: `For movers and replacements: add the --to user to every C3PO group the --from
+user is in and --to is not. With --move, the --from user is then removed from
+all of their groups, so the access is transferred rather than mirrored.
+
+The plan is shown before anything changes. The --to user must be active.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pCopyFrom == "" || pCopyTo == "" {
+			log.Fatalf("ERROR: --from and --to are required")
+		}
+
+		from, err := sClient.LookupUser(pCopyFrom)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		to, err := sClient.LookupUser(pCopyTo)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		plan, err := state.PlanCopyAccess(from, to, pCopyMove)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if len(plan.Changes) == 0 {
+			fmt.Fprintf(os.Stderr, "%s is already in every group %s is in.\n", to.IdAtSourceSystem, from.IdAtSourceSystem)
+			return
+		}
+
+		if err := sClient.PrintPlan(plan); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		verb := "Copy"
+		if pCopyMove {
+			verb = "Move"
+		}
+		if !pDryRun && !confirm(fmt.Sprintf("%s access from %s to %s?", verb, from.IdAtSourceSystem, to.IdAtSourceSystem)) {
+			fmt.Fprintln(os.Stderr, "Aborted.")
+			return
+		}
+
+		applied, err := sClient.ApplyPlan(plan, state, func(change c3po.Change, err error) {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "FAILED  %s %s %q: %v\n", change.Action, change.Kind, change.Target, err)
+			} else if !pDryRun {
+				fmt.Fprintf(os.Stderr, "OK      %s %s %q\n", change.Action, change.Kind, change.Target)
+			}
+		})
+
+		added, _, removed := c3po.CountChanges(applied)
+		fmt.Fprintf(os.Stderr, "\n%s complete: %d added, %d removed", verb, added, removed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, ", %d not applied\n", len(plan.Changes)-len(applied))
+			log.Printf("ERROR: %v", err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, ".")
+	},
+}
+
+// userGroupDiff is one group in a user comparison, with who is in it.
+type userGroupDiff struct {
+	Group  string
+	Member string
+}
+
+func printUserComparison(comparison c3po.UserComparison) error {
+	a, b := comparison.A.IdAtSourceSystem, comparison.B.IdAtSourceSystem
+
+	var rows []userGroupDiff
+	for _, group := range comparison.OnlyA {
+		rows = append(rows, userGroupDiff{Group: group, Member: a})
+	}
+	for _, group := range comparison.OnlyB {
+		rows = append(rows, userGroupDiff{Group: group, Member: b})
+	}
+	for _, group := range comparison.Common {
+		rows = append(rows, userGroupDiff{Group: group, Member: "both"})
+	}
+
+	columns := []c3po.Column{
+		{Name: "Group", Value: func(v interface{}) string { return v.(userGroupDiff).Group }},
+		{Name: "Member", Value: func(v interface{}) string { return v.(userGroupDiff).Member }},
+	}
+
+	return c3po.PrintItems(rows, columns, func() {
+		sections := []struct {
+			title  string
+			groups []string
+		}{
+			{"Only " + a + " (" + comparison.A.CommonName + ")", comparison.OnlyA},
+			{"Only " + b + " (" + comparison.B.CommonName + ")", comparison.OnlyB},
+			{"Both", comparison.Common},
+		}
+		for i, section := range sections {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s: %d group(s)\n", section.title, len(section.groups))
+			for _, group := range section.groups {
+				fmt.Println("  " + group)
+			}
+		}
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(UserCmd)
+	UserCmd.AddCommand(userCompareCmd, userCopyAccessCmd)
+
+	userCopyAccessCmd.Flags().StringVarP(&pCopyFrom, "from", "", "", "HubID of the user whose groups are copied")
+	userCopyAccessCmd.Flags().StringVarP(&pCopyTo, "to", "", "", "HubID of the user to add to them")
+	userCopyAccessCmd.Flags().BoolVarP(&pCopyMove, "move", "", false, "Also remove the --from user from all of their groups")
+
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	c3po "github.com/comdol2/c3po/api"
+	"github.com/spf13/cobra"
+)
+
+var pWhoHasMatch string
+
+// WhoHasCmd lists the users holding some access
+var WhoHasCmd = &cobra.Command{
+	Use:   "who-has (--ability NAME | --role NAME | --nimbusfolder NAME)",
+	Short: "List every user who effectively has a role, functional ability or Nimbus folder",
+	Long: `Find the roles matching --role, or carrying a functional ability matching --ability
+or giving access to the Nimbus folder --nimbusfolder, expand every group bound to
+them and list their users once each, with the groups and roles granting the access.
+When several options are given, users holding any of them are listed.
+
+Names are matched exactly (ignoring case) unless --match says otherwise.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pRoleName == "" && pAbilityName == "" && pNimbusFolderName == "" {
+			log.Fatalf("ERROR: give --ability, --role or --nimbusfolder")
+		}
+
+		mode, err := c3po.ParseMatchMode(pWhoHasMatch)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		state, err := sClient.FetchState()
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		holders, err := sClient.WhoHas(state, c3po.AccessQuery{Role: pRoleName, Ability: pAbilityName, NimbusFolder: pNimbusFolderName, Mode: mode})
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+
+		if len(holders) == 0 && c3po.TextOutput() {
+			fmt.Println("Nobody has this access.")
+			return
+		}
+
+		if err := printAccessHolders(holders); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	},
+}
+
+func printAccessHolders(holders []c3po.AccessHolder) error {
+	columns := []c3po.Column{
+		{Name: "HubID", Value: func(v interface{}) string { return v.(c3po.AccessHolder).User.IdAtSourceSystem }},
+		{Name: "CommonName", Value: func(v interface{}) string { return v.(c3po.AccessHolder).User.CommonName }},
+		{Name: "Email", Value: func(v interface{}) string { return v.(c3po.AccessHolder).User.Email }},
+		{Name: "IsActive", Value: func(v interface{}) string { return fmt.Sprint(v.(c3po.AccessHolder).User.IsActive) }},
+		{Name: "SourceSystemName", Value: func(v interface{}) string { return v.(c3po.AccessHolder).User.SourceSystemName }},
+		{Name: "Groups", Value: func(v interface{}) string { return strings.Join(v.(c3po.AccessHolder).Groups, ",") }},
+		{Name: "Roles", Value: func(v interface{}) string { return strings.Join(v.(c3po.AccessHolder).Roles, ",") }},
+	}
+
+	return c3po.PrintItems(holders, columns, func() {
+		for _, holder := range holders {
+			active := "active"
+			if !holder.User.IsActive {
+				active = "INACTIVE"
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", holder.User.IdAtSourceSystem, holder.User.CommonName, holder.User.Email, active, holder.User.SourceSystemName)
+			fmt.Printf("\tvia %s\n", strings.Join(holder.Groups, ", "))
+		}
+		fmt.Printf("\n%d user(s).\n", len(holders))
+	})
+}
+
+func init() {
+
+	RootCmd.AddCommand(WhoHasCmd)
+
+	WhoHasCmd.Flags().StringVarP(&pAbilityName, "ability", "a", "", "Functional ability name")
+	WhoHasCmd.Flags().StringVarP(&pRoleName, "role", "r", "", "Role name")
+	WhoHasCmd.Flags().StringVarP(&pNimbusFolderName, "nimbusfolder", "n", "", "Nimbus folder name, as found in the functional abilities' entity access")
+	WhoHasCmd.Flags().StringVarP(&pWhoHasMatch, "match", "m", "exact", "How to match names: exact, auto (ranked substring), glob, regex or fuzzy")
+
+}
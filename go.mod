@@ -5,6 +5,7 @@ go 1.21.1
 require (
 	github.com/spf13/cobra v1.8.0
 	golang.org/x/term v0.16.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (